@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+)
+
+// ActionKind identifies a logged action. The values are stable identifiers
+// for the JSON Lines output; the text log shows the label instead.
+type ActionKind string
+
+const (
+	ActionRegisterUser      ActionKind = "user_register"
+	ActionCreateSubreddit   ActionKind = "subreddit_create"
+	ActionJoinSubreddit     ActionKind = "subreddit_join"
+	ActionLeaveSubreddit    ActionKind = "subreddit_leave"
+	ActionCreatePost        ActionKind = "post_create"
+	ActionCreateComment     ActionKind = "comment_create"
+	ActionCommentReply      ActionKind = "comment_reply"
+	ActionEditPost          ActionKind = "post_edit"
+	ActionDeletePost        ActionKind = "post_delete"
+	ActionEditComment       ActionKind = "comment_edit"
+	ActionDeleteComment     ActionKind = "comment_delete"
+	ActionVote              ActionKind = "vote"
+	ActionDirectMessage     ActionKind = "direct_message"
+	ActionShowFeed          ActionKind = "feed_show"
+	ActionBlockUser         ActionKind = "user_block"
+	ActionUnblockUser       ActionKind = "user_unblock"
+	ActionAddModerator      ActionKind = "moderator_add"
+	ActionRemoveModerator   ActionKind = "moderator_remove"
+	ActionRemovePost        ActionKind = "post_remove"
+	ActionRemoveComment     ActionKind = "comment_remove"
+	ActionBanUser           ActionKind = "user_ban"
+	ActionUnbanUser         ActionKind = "user_unban"
+	ActionMuteUser          ActionKind = "user_mute"
+	ActionUnmuteUser        ActionKind = "user_unmute"
+	ActionReport            ActionKind = "report"
+	ActionApproveReported   ActionKind = "report_approve"
+	ActionEditSubreddit     ActionKind = "subreddit_edit"
+	ActionAddRule           ActionKind = "rule_add"
+	ActionEditRule          ActionKind = "rule_edit"
+	ActionRemoveRule        ActionKind = "rule_remove"
+	ActionSetSubredditType  ActionKind = "subreddit_type_set"
+	ActionAddPostFlair      ActionKind = "post_flair_add"
+	ActionRemovePostFlair   ActionKind = "post_flair_remove"
+	ActionSetUserFlair      ActionKind = "user_flair_set"
+	ActionSetPolicy         ActionKind = "policy_set"
+	ActionApproveUser       ActionKind = "user_approve"
+	ActionUnapproveUser     ActionKind = "user_unapprove"
+	ActionPinPost           ActionKind = "post_pin"
+	ActionUnpinPost         ActionKind = "post_unpin"
+	ActionLockPost          ActionKind = "post_lock"
+	ActionUnlockPost        ActionKind = "post_unlock"
+	ActionAutoMod           ActionKind = "automod"
+	ActionAddAutoModRule    ActionKind = "automod_rule_add"
+	ActionRemoveAutoModRule ActionKind = "automod_rule_remove"
+)
+
+// actionLabels are the bracketed tags the text log shows for each kind.
+var actionLabels = map[ActionKind]string{
+	ActionRegisterUser:      "REGISTER USER",
+	ActionCreateSubreddit:   "CREATE SUB",
+	ActionJoinSubreddit:     "JOIN SUB",
+	ActionLeaveSubreddit:    "LEAVE SUB",
+	ActionCreatePost:        "POST",
+	ActionCreateComment:     "POST Comment",
+	ActionCommentReply:      "COMMENT REPLY",
+	ActionEditPost:          "EDIT POST",
+	ActionDeletePost:        "DELETE POST",
+	ActionEditComment:       "EDIT COMMENT",
+	ActionDeleteComment:     "DELETE COMMENT",
+	ActionVote:              "VOTE",
+	ActionDirectMessage:     "Direct Message",
+	ActionShowFeed:          "SHOW FEED",
+	ActionBlockUser:         "BLOCK",
+	ActionUnblockUser:       "UNBLOCK",
+	ActionAddModerator:      "ADD MOD",
+	ActionRemoveModerator:   "REMOVE MOD",
+	ActionRemovePost:        "REMOVE POST",
+	ActionRemoveComment:     "REMOVE COMMENT",
+	ActionBanUser:           "BAN",
+	ActionUnbanUser:         "UNBAN",
+	ActionMuteUser:          "MUTE",
+	ActionUnmuteUser:        "UNMUTE",
+	ActionReport:            "REPORT",
+	ActionApproveReported:   "APPROVE",
+	ActionEditSubreddit:     "EDIT SUB",
+	ActionAddRule:           "ADD RULE",
+	ActionEditRule:          "EDIT RULE",
+	ActionRemoveRule:        "DEL RULE",
+	ActionSetSubredditType:  "SET SUB TYPE",
+	ActionAddPostFlair:      "ADD FLAIR",
+	ActionRemovePostFlair:   "DEL FLAIR",
+	ActionSetUserFlair:      "USER FLAIR",
+	ActionSetPolicy:         "SET POLICY",
+	ActionApproveUser:       "APPROVE USER",
+	ActionUnapproveUser:     "UNAPPROVE USER",
+	ActionPinPost:           "PIN",
+	ActionUnpinPost:         "UNPIN",
+	ActionLockPost:          "LOCK",
+	ActionUnlockPost:        "UNLOCK",
+	ActionAutoMod:           "AUTOMOD",
+	ActionAddAutoModRule:    "ADD AUTOMOD",
+	ActionRemoveAutoModRule: "DEL AUTOMOD",
+}
+
+func (k ActionKind) label() string {
+	if label, exists := actionLabels[k]; exists {
+		return label
+	}
+	return string(k)
+}
+
+// ActionRetention bounds how many logged actions the engine keeps in memory
+// per user. Zero values mean no limit. Entries pushed out of memory are
+// appended to SpillPath as JSON Lines, or dropped when it is empty.
+type ActionRetention struct {
+	MaxPerUser int
+	MaxAge     time.Duration
+	SpillPath  string
+}
+
+func (r ActionRetention) enabled() bool {
+	return r.MaxPerUser > 0 || r.MaxAge > 0
+}
+
+// expired returns how many of the oldest actions fall outside the retention
+// window at now.
+func (r ActionRetention) expired(actions []UserAction, now time.Time) int {
+	n := 0
+	if r.MaxPerUser > 0 && len(actions) > r.MaxPerUser {
+		n = len(actions) - r.MaxPerUser
+	}
+	if r.MaxAge > 0 {
+		cutoff := now.Add(-r.MaxAge)
+		for n < len(actions) && actions[n].Timestamp.Before(cutoff) {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders the action the way the text log shows it: the bracketed kind
+// padded to a fixed column followed by a description.
+func (a UserAction) String() string {
+	var text string
+	switch a.Kind {
+	case ActionRegisterUser:
+		text = "Registered as new user"
+	case ActionCreateSubreddit:
+		text = fmt.Sprintf("Subreddit created: %s by %s", a.Subreddit, a.Actor)
+		if a.Content != "" && a.Content != string(SubredditPublic) {
+			text += fmt.Sprintf(" (%s)", a.Content)
+		}
+	case ActionJoinSubreddit:
+		text = fmt.Sprintf("%s joined subreddit %s", a.Actor, a.Subreddit)
+	case ActionLeaveSubreddit:
+		text = fmt.Sprintf("%s left subreddit %s", a.Actor, a.Subreddit)
+	case ActionCreatePost:
+		text = fmt.Sprintf("%s created in %s by %s: %s", a.PostID, a.Subreddit, a.Actor, a.Title)
+	case ActionCreateComment:
+		text = fmt.Sprintf("%s commented on post %s: %s", a.Actor, a.PostID, a.Content)
+	case ActionCommentReply:
+		text = fmt.Sprintf("%s commented on %s: %s", a.Actor, a.ParentID, a.Content)
+	case ActionEditPost:
+		text = fmt.Sprintf("%s edited post %s: %s", a.Actor, a.PostID, a.Content)
+	case ActionDeletePost:
+		text = fmt.Sprintf("%s deleted post %s", a.Actor, a.PostID)
+	case ActionEditComment:
+		text = fmt.Sprintf("%s edited comment %s on post %s: %s", a.Actor, a.CommentID, a.PostID, a.Content)
+	case ActionDeleteComment:
+		text = fmt.Sprintf("%s deleted comment %s on post %s", a.Actor, a.CommentID, a.PostID)
+	case ActionVote:
+		text = fmt.Sprintf("%s %s post %s", a.Actor, a.VoteType, a.PostID)
+	case ActionDirectMessage:
+		text = fmt.Sprintf("DM sent to %s: %s", a.TargetUser, a.Content)
+		if a.ParentID != "" {
+			text = fmt.Sprintf("DM reply to %s sent to %s: %s", a.ParentID, a.TargetUser, a.Content)
+		}
+	case ActionShowFeed:
+		text = fmt.Sprintf("Feed for user %s ----- ", a.Actor)
+		// feed posts are listed under the header without a tag
+		for _, postID := range a.PostIDs {
+			text += fmt.Sprintf("\n%17s%s", "", postID)
+		}
+	case ActionBlockUser:
+		text = fmt.Sprintf("%s blocked %s", a.Actor, a.TargetUser)
+	case ActionUnblockUser:
+		text = fmt.Sprintf("%s unblocked %s", a.Actor, a.TargetUser)
+	case ActionAddModerator:
+		text = fmt.Sprintf("%s made %s a moderator of %s", a.Actor, a.TargetUser, a.Subreddit)
+	case ActionRemoveModerator:
+		text = fmt.Sprintf("%s removed %s as moderator of %s", a.Actor, a.TargetUser, a.Subreddit)
+	case ActionRemovePost:
+		text = fmt.Sprintf("%s removed post %s in %s", a.Actor, a.PostID, a.Subreddit)
+	case ActionRemoveComment:
+		text = fmt.Sprintf("%s removed comment %s on post %s", a.Actor, a.CommentID, a.PostID)
+	case ActionBanUser:
+		text = fmt.Sprintf("%s banned %s from %s: %s", a.Actor, a.TargetUser, a.Subreddit, a.Content)
+	case ActionUnbanUser:
+		text = fmt.Sprintf("%s unbanned %s from %s", a.Actor, a.TargetUser, a.Subreddit)
+	case ActionMuteUser:
+		text = fmt.Sprintf("%s muted %s in %s: %s", a.Actor, a.TargetUser, a.Subreddit, a.Content)
+	case ActionUnmuteUser:
+		text = fmt.Sprintf("%s unmuted %s in %s", a.Actor, a.TargetUser, a.Subreddit)
+	case ActionReport:
+		text = fmt.Sprintf("%s reported %s by %s: %s", a.Actor, reportedItemName(a), a.TargetUser, a.Content)
+	case ActionApproveReported:
+		text = fmt.Sprintf("%s approved reported %s", a.Actor, reportedItemName(a))
+	case ActionEditSubreddit:
+		text = fmt.Sprintf("%s updated the description of %s: %s", a.Actor, a.Subreddit, a.Content)
+	case ActionAddRule:
+		text = fmt.Sprintf("%s added a rule to %s (%s)", a.Actor, a.Subreddit, a.Content)
+	case ActionEditRule:
+		text = fmt.Sprintf("%s edited a rule of %s (%s)", a.Actor, a.Subreddit, a.Content)
+	case ActionRemoveRule:
+		text = fmt.Sprintf("%s removed a rule from %s (%s)", a.Actor, a.Subreddit, a.Content)
+	case ActionAddPostFlair:
+		text = fmt.Sprintf("%s added post flair %q to %s", a.Actor, a.Content, a.Subreddit)
+	case ActionRemovePostFlair:
+		text = fmt.Sprintf("%s removed post flair %q from %s", a.Actor, a.Content, a.Subreddit)
+	case ActionSetUserFlair:
+		text = fmt.Sprintf("%s set the flair of %s in %s to %q", a.Actor, a.TargetUser, a.Subreddit, a.Content)
+	case ActionSetSubredditType:
+		text = fmt.Sprintf("%s made %s %s", a.Actor, a.Subreddit, a.Content)
+	case ActionSetPolicy:
+		text = fmt.Sprintf("%s set membership policy of %s: %s", a.Actor, a.Subreddit, a.Content)
+	case ActionApproveUser:
+		text = fmt.Sprintf("%s approved %s in %s", a.Actor, a.TargetUser, a.Subreddit)
+	case ActionUnapproveUser:
+		text = fmt.Sprintf("%s unapproved %s in %s", a.Actor, a.TargetUser, a.Subreddit)
+	case ActionPinPost:
+		text = fmt.Sprintf("%s pinned post %s in %s", a.Actor, a.PostID, a.Subreddit)
+	case ActionUnpinPost:
+		text = fmt.Sprintf("%s unpinned post %s in %s", a.Actor, a.PostID, a.Subreddit)
+	case ActionLockPost:
+		text = fmt.Sprintf("%s locked post %s in %s", a.Actor, a.PostID, a.Subreddit)
+	case ActionUnlockPost:
+		text = fmt.Sprintf("%s unlocked post %s in %s", a.Actor, a.PostID, a.Subreddit)
+	case ActionAutoMod:
+		text = fmt.Sprintf("%s by %s hit rule %s", reportedItemName(a), a.TargetUser, a.Content)
+	case ActionAddAutoModRule:
+		text = fmt.Sprintf("%s added automod rule %q to %s", a.Actor, a.Content, a.Subreddit)
+	case ActionRemoveAutoModRule:
+		text = fmt.Sprintf("%s removed automod rule %q from %s", a.Actor, a.Content, a.Subreddit)
+	default:
+		text = a.Content
+	}
+	return fmt.Sprintf("%-16s %s", "["+a.Kind.label()+"]", text)
+}
+
+func reportedItemName(a UserAction) string {
+	switch {
+	case a.CommentID != "":
+		return fmt.Sprintf("comment %s on post %s", a.CommentID, a.PostID)
+	case a.PostID != "":
+		return "post " + a.PostID
+	default:
+		return "message " + a.MessageID
+	}
+}
+
+// approxSize estimates the heap bytes held by an action, counting the struct
+// itself plus its string and slice payloads.
+func (a UserAction) approxSize() int {
+	size := int(unsafe.Sizeof(a))
+	size += len(a.Kind) + len(a.Actor) + len(a.Subreddit) + len(a.PostID) + len(a.CommentID) +
+		len(a.ParentID) + len(a.MessageID) + len(a.TargetUser) + len(a.Title) + len(a.Content) + len(a.VoteType)
+	for _, postID := range a.PostIDs {
+		size += int(unsafe.Sizeof(postID)) + len(postID)
+	}
+	return size
+}
+
+// writeActionsJSONLines writes one JSON object per action.
+func writeActionsJSONLines(w io.Writer, actions []UserAction) error {
+	encoder := json.NewEncoder(w)
+	for _, action := range actions {
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
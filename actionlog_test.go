@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActionRetentionExpired(t *testing.T) {
+	now := time.Now()
+	actionsAged := func(ages ...time.Duration) []UserAction {
+		var actions []UserAction
+		for _, age := range ages {
+			actions = append(actions, UserAction{Timestamp: now.Add(-age)})
+		}
+		return actions
+	}
+	tests := []struct {
+		name      string
+		retention ActionRetention
+		actions   []UserAction
+		want      int
+	}{
+		{"no limits", ActionRetention{}, actionsAged(time.Hour, time.Minute), 0},
+		{"under the count", ActionRetention{MaxPerUser: 3}, actionsAged(3, 2, 1), 0},
+		{"over the count", ActionRetention{MaxPerUser: 2}, actionsAged(3, 2, 1), 1},
+		{"older than the window", ActionRetention{MaxAge: time.Minute}, actionsAged(3*time.Minute, 2*time.Minute, 30*time.Second), 2},
+		{"all expired", ActionRetention{MaxAge: time.Minute}, actionsAged(3*time.Minute, 2*time.Minute), 2},
+		{"count then window", ActionRetention{MaxPerUser: 2, MaxAge: time.Minute}, actionsAged(3*time.Minute, 2*time.Minute, 30*time.Second), 2},
+		{"window within the count", ActionRetention{MaxPerUser: 2, MaxAge: time.Minute}, actionsAged(3*time.Minute, 30*time.Second, 10*time.Second), 1},
+		{"empty", ActionRetention{MaxPerUser: 1, MaxAge: time.Minute}, nil, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.retention.expired(tt.actions, now); got != tt.want {
+			t.Errorf("%s: expired = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestActionKindRendering(t *testing.T) {
+	action := UserAction{Kind: ActionCreateComment, Actor: "alice", PostID: "Post 1", Content: "hi"}
+	if got, want := action.String(), "[POST Comment]   alice commented on post Post 1: hi"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	var out strings.Builder
+	if err := writeActionsJSONLines(&out, []UserAction{action}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"kind":"comment_create"`) {
+		t.Errorf("JSON Lines output %s lacks the stable kind", out.String())
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// autoModerator is the moderator name recorded for automatic rule actions.
+const autoModerator = "AutoModerator"
+
+var linkPattern = regexp.MustCompile(`https?://([^/\s:?#]+)`)
+
+// matches reports whether every condition set on the rule holds for a post or
+// comment by author. Content conditions match against title and content.
+func (r *AutoModRule) matches(author *User, title, content string, now time.Time) bool {
+	text := title + "\n" + content
+	if len(r.Keywords) > 0 {
+		lower := strings.ToLower(text)
+		found := false
+		for _, keyword := range r.Keywords {
+			if strings.Contains(lower, strings.ToLower(keyword)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.pattern != nil && !r.pattern.MatchString(text) {
+		return false
+	}
+	if len(r.BlockedDomains) > 0 && !linksToDomain(text, r.BlockedDomains) {
+		return false
+	}
+	if r.MinKarma != 0 && author.Karma >= r.MinKarma {
+		return false
+	}
+	if r.MinAccountAge > 0 && now.Sub(author.Created) >= r.MinAccountAge {
+		return false
+	}
+	return true
+}
+
+func linksToDomain(text string, domains []string) bool {
+	for _, match := range linkPattern.FindAllStringSubmatch(text, -1) {
+		host := strings.ToLower(match[1])
+		for _, domain := range domains {
+			domain = strings.ToLower(domain)
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchAutoModRule returns the first of the subreddit's rules that fires for
+// the given submission, or nil.
+func (e *Engine) matchAutoModRule(subreddit *Subreddit, author, title, content string) *AutoModRule {
+	user, exists := e.users[author]
+	if !exists {
+		return nil
+	}
+	now := time.Now()
+	for _, rule := range subreddit.AutoModRules {
+		if rule.matches(user, title, content, now) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (e *Engine) applyAutoModToPost(subreddit *Subreddit, rule *AutoModRule, post *Post) {
+	reason := fmt.Sprintf("%s: %s", rule.Name, rule.Action)
+	switch rule.Action {
+	case AutoModRemove:
+		post.RemovedBy = autoModerator
+	case AutoModHold:
+		post.Held = true
+		e.holdForReview(&ReportedItem{TargetType: ReportPost, SubredditName: subreddit.Name, PostID: post.ID, Author: post.Author}, rule.Name)
+	case AutoModTag:
+		post.Tags = append(post.Tags, rule.Tag)
+		reason = fmt.Sprintf("%s: %s %s", rule.Name, rule.Action, rule.Tag)
+	}
+	e.logModAction(subreddit, UserAction{Kind: ActionAutoMod, Actor: autoModerator, Subreddit: subreddit.Name,
+		PostID: post.ID, TargetUser: post.Author, Content: reason})
+}
+
+func (e *Engine) applyAutoModToComment(subreddit *Subreddit, rule *AutoModRule, post *Post, comment *Comment) {
+	reason := fmt.Sprintf("%s: %s", rule.Name, rule.Action)
+	switch rule.Action {
+	case AutoModRemove:
+		comment.RemovedBy = autoModerator
+	case AutoModHold:
+		comment.Held = true
+		e.holdForReview(&ReportedItem{TargetType: ReportComment, SubredditName: subreddit.Name, PostID: post.ID, CommentID: comment.ID, Author: comment.Author}, rule.Name)
+	case AutoModTag:
+		comment.Tags = append(comment.Tags, rule.Tag)
+		reason = fmt.Sprintf("%s: %s %s", rule.Name, rule.Action, rule.Tag)
+	}
+	e.logModAction(subreddit, UserAction{Kind: ActionAutoMod, Actor: autoModerator, Subreddit: subreddit.Name,
+		PostID: post.ID, CommentID: comment.ID, TargetUser: comment.Author, Content: reason})
+}
+
+func (e *Engine) addAutoModRule(subredditName, moderator string, rule AutoModRule) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if rule.Name == "" {
+		return fmt.Errorf("automod rule needs a name")
+	}
+	// a rule without conditions would match every post and comment
+	if len(rule.Keywords) == 0 && rule.Pattern == "" && len(rule.BlockedDomains) == 0 && rule.MinKarma == 0 && rule.MinAccountAge <= 0 {
+		return fmt.Errorf("automod rule %q has no conditions", rule.Name)
+	}
+	for _, existing := range subreddit.AutoModRules {
+		if existing.Name == rule.Name {
+			return fmt.Errorf("automod rule %q already exists in %s", rule.Name, subredditName)
+		}
+	}
+	switch rule.Action {
+	case AutoModRemove, AutoModHold:
+	case AutoModTag:
+		if rule.Tag == "" {
+			return fmt.Errorf("automod rule %q tags posts but has no tag", rule.Name)
+		}
+	default:
+		return fmt.Errorf("unknown automod action %q", rule.Action)
+	}
+	if rule.Pattern != "" {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("automod rule %q: %v", rule.Name, err)
+		}
+		rule.pattern = pattern
+	}
+	subreddit.AutoModRules = append(subreddit.AutoModRules, &rule)
+	e.logModAction(subreddit, UserAction{Kind: ActionAddAutoModRule, Actor: moderator, Subreddit: subredditName, Content: rule.Name})
+	return nil
+}
+
+func (e *Engine) removeAutoModRule(subredditName, moderator, name string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	for i, rule := range subreddit.AutoModRules {
+		if rule.Name == name {
+			subreddit.AutoModRules = append(subreddit.AutoModRules[:i], subreddit.AutoModRules[i+1:]...)
+			e.logModAction(subreddit, UserAction{Kind: ActionRemoveAutoModRule, Actor: moderator, Subreddit: subredditName, Content: name})
+			return nil
+		}
+	}
+	return fmt.Errorf("automod rule %q does not exist in %s", name, subredditName)
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestAddAutoModRuleRequiresConditions(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.addAutoModRule("r/test", "alice", AutoModRule{Name: "everything", Action: AutoModRemove}); err == nil {
+		t.Fatal("a rule without conditions was accepted")
+	}
+	if err := e.createPost("Post 2", "r/test", "alice", "Ordinary", "Nothing to see", ""); err != nil {
+		t.Fatal(err)
+	}
+	if post := e.posts["Post 2"]; post.RemovedBy != "" {
+		t.Fatalf("ordinary post was removed by %s", post.RemovedBy)
+	}
+	if err := e.addAutoModRule("r/test", "alice", AutoModRule{Name: "spam", Keywords: []string{"deals"}, Action: AutoModRemove}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "fmt"
+
+// hasBlocked reports whether username blocked other.
+func (e *Engine) hasBlocked(username, other string) bool {
+	user, exists := e.users[username]
+	return exists && contains(user.BlockedUsers, other)
+}
+
+func (e *Engine) blockUser(username, blocked string) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if _, exists := e.users[blocked]; !exists {
+		return fmt.Errorf("user %s does not exist", blocked)
+	}
+	if username == blocked {
+		return fmt.Errorf("%s cannot block themselves", username)
+	}
+	if contains(user.BlockedUsers, blocked) {
+		return fmt.Errorf("%s already blocked %s", username, blocked)
+	}
+	user.BlockedUsers = append(user.BlockedUsers, blocked)
+	e.logUserAction(UserAction{Kind: ActionBlockUser, Actor: username, TargetUser: blocked})
+	return nil
+}
+
+func (e *Engine) unblockUser(username, blocked string) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if !contains(user.BlockedUsers, blocked) {
+		return fmt.Errorf("%s has not blocked %s", username, blocked)
+	}
+	user.BlockedUsers = remove(user.BlockedUsers, blocked)
+	e.logUserAction(UserAction{Kind: ActionUnblockUser, Actor: username, TargetUser: blocked})
+	return nil
+}
+
+func (e *Engine) getBlockedUsers(username string) (*BlockedUsers, error) {
+	user, exists := e.users[username]
+	if !exists {
+		return nil, fmt.Errorf("user %s does not exist", username)
+	}
+	return &BlockedUsers{Username: username, Blocked: append([]string(nil), user.BlockedUsers...)}, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestBlockedUserCannotMessageOrReply(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.sendDirectMessage(&SendDirectMessage{MessageID: "DM 1", From: "bob", To: "alice", Content: "before"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "alice", "my own comment"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.blockUser("alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.sendDirectMessage(&SendDirectMessage{From: "bob", To: "alice", Content: "new"}); err == nil {
+		t.Error("a blocked user started a conversation")
+	}
+	if err := e.sendDirectMessage(&SendDirectMessage{ReplyTo: "DM 1", From: "bob", To: "alice", Content: "again"}); err == nil {
+		t.Error("a blocked user replied to an earlier message")
+	}
+	for _, parentID := range []string{"Post 1", "Comment 1"} {
+		if err := e.createComment("Post 1", parentID, "Comment 2", "bob", "reply"); err == nil {
+			t.Errorf("a blocked user replied to %s", parentID)
+		}
+	}
+	if got := len(e.users["alice"].ReceivedMessages); got != 1 {
+		t.Errorf("alice received %d messages, want 1", got)
+	}
+
+	// blocking is one-way
+	if err := e.sendDirectMessage(&SendDirectMessage{From: "alice", To: "bob", Content: "still allowed"}); err != nil {
+		t.Errorf("the blocker cannot message: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func (e *Engine) sendDirectMessage(msg *SendDirectMessage) error {
+	fromUser, exists := e.users[msg.From]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", msg.From)
+	}
+	toUser, exists := e.users[msg.To]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", msg.To)
+	}
+	if e.hasBlocked(msg.To, msg.From) {
+		return fmt.Errorf("%s is not accepting messages from %s", msg.To, msg.From)
+	}
+	messageID := msg.MessageID
+	if messageID == "" {
+		messageID = e.nextMessageID()
+	}
+	if _, exists := e.directMessages[messageID]; exists {
+		return fmt.Errorf("message %s already exists", messageID)
+	}
+
+	var conversation *Conversation
+	if msg.ReplyTo != "" {
+		parent, exists := e.directMessages[msg.ReplyTo]
+		if !exists {
+			return fmt.Errorf("message %s does not exist", msg.ReplyTo)
+		}
+		conversation = e.conversations[parent.ConversationID]
+		if !contains(conversation.Participants, msg.From) || !contains(conversation.Participants, msg.To) {
+			return fmt.Errorf("message %s is not between %s and %s", msg.ReplyTo, msg.From, msg.To)
+		}
+	} else {
+		conversation = &Conversation{ID: messageID, Participants: []string{msg.From, msg.To}}
+		e.conversations[conversation.ID] = conversation
+	}
+
+	message := &DirectMessage{ID: messageID, ConversationID: conversation.ID, ReplyTo: msg.ReplyTo,
+		From: msg.From, To: msg.To, Content: msg.Content, Sent: time.Now()}
+	e.directMessages[messageID] = message
+	conversation.Messages = append(conversation.Messages, message)
+	fromUser.SentMessages = append(fromUser.SentMessages, message)
+	toUser.ReceivedMessages = append(toUser.ReceivedMessages, message)
+	e.deliverMessage(message)
+	e.logUserAction(UserAction{Kind: ActionDirectMessage, Actor: msg.From, TargetUser: msg.To, MessageID: messageID, ParentID: msg.ReplyTo, Content: msg.Content})
+	return nil
+}
+
+// nextMessageID numbers messages sent without an ID, skipping numbers that
+// callers already used for their own IDs.
+func (e *Engine) nextMessageID() string {
+	for {
+		e.lastMessageSeq++
+		messageID := fmt.Sprintf("DM %d", e.lastMessageSeq)
+		if _, exists := e.directMessages[messageID]; !exists {
+			return messageID
+		}
+	}
+}
+
+// otherParticipant returns who username is talking to in conversation.
+func otherParticipant(conversation *Conversation, username string) string {
+	for _, participant := range conversation.Participants {
+		if participant != username {
+			return participant
+		}
+	}
+	return username
+}
+
+func (e *Engine) getConversations(query *GetConversations) (*ConversationList, error) {
+	if _, exists := e.users[query.Username]; !exists {
+		return nil, fmt.Errorf("user %s does not exist", query.Username)
+	}
+	var summaries []ConversationSummary
+	for _, conversation := range e.conversations {
+		if !contains(conversation.Participants, query.Username) {
+			continue
+		}
+		summary := ConversationSummary{
+			ID:           conversation.ID,
+			With:         otherParticipant(conversation, query.Username),
+			LastMessage:  *conversation.Messages[len(conversation.Messages)-1],
+			MessageCount: len(conversation.Messages),
+		}
+		for _, message := range conversation.Messages {
+			if message.To == query.Username && !message.Read {
+				summary.Unread++
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].LastMessage.Sent.Equal(summaries[j].LastMessage.Sent) {
+			return summaries[i].LastMessage.Sent.After(summaries[j].LastMessage.Sent)
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+
+	start, end := paginate(len(summaries), query.Offset, query.Limit)
+	list := &ConversationList{Conversations: summaries[start:end], Total: len(summaries), NextOffset: -1}
+	if end < len(summaries) {
+		list.NextOffset = end
+	}
+	return list, nil
+}
+
+func (e *Engine) getConversation(query *GetConversation) (*ConversationMessages, error) {
+	conversation, exists := e.conversations[query.ConversationID]
+	if !exists || !contains(conversation.Participants, query.Username) {
+		return nil, fmt.Errorf("%s has no conversation %s", query.Username, query.ConversationID)
+	}
+	start, end := paginate(len(conversation.Messages), query.Offset, query.Limit)
+	page := &ConversationMessages{ID: conversation.ID, Total: len(conversation.Messages), NextOffset: -1}
+	for _, message := range conversation.Messages[start:end] {
+		if message.To == query.Username {
+			message.Read = true
+		}
+		page.Messages = append(page.Messages, *message)
+	}
+	if end < len(conversation.Messages) {
+		page.NextOffset = end
+	}
+	return page, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestSendDirectMessageAssignsUnusedIDs(t *testing.T) {
+	e := newTestEngine(t)
+	sends := []*SendDirectMessage{
+		{From: "alice", To: "bob", Content: "first"},
+		{MessageID: "DM 2", From: "alice", To: "bob", Content: "named"},
+		{From: "bob", To: "alice", Content: "second"},
+		{From: "bob", To: "alice", Content: "third"},
+	}
+	for _, send := range sends {
+		if err := e.sendDirectMessage(send); err != nil {
+			t.Fatalf("sending %q: %v", send.Content, err)
+		}
+	}
+	want := map[string]string{"DM 1": "first", "DM 2": "named", "DM 3": "second", "DM 4": "third"}
+	for messageID, content := range want {
+		if message := e.directMessages[messageID]; message == nil || message.Content != content {
+			t.Errorf("message %s = %+v, want content %q", messageID, message, content)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// directoryName is the form subreddit names are matched in: lowercase and
+// without the "r/" prefix.
+func directoryName(name string) string {
+	return strings.TrimPrefix(strings.ToLower(name), "r/")
+}
+
+// editDistance returns the number of single rune insertions, deletions,
+// substitutions and adjacent swaps turning a into b.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// matchesDirectoryQuery reports whether a subreddit turns up for query: its
+// description contains the query, or its name starts with it. Queries of three
+// or more characters may be off by one typo, plus one per further eight.
+func matchesDirectoryQuery(subreddit *Subreddit, query string) bool {
+	query = directoryName(query)
+	if query == "" {
+		return true
+	}
+	name := directoryName(subreddit.Name)
+	if strings.HasPrefix(name, query) || strings.Contains(strings.ToLower(subreddit.Description), query) {
+		return true
+	}
+	queryRunes, nameRunes := []rune(query), []rune(name)
+	if len(queryRunes) < 3 {
+		return false
+	}
+	maxEdits := 1 + len(queryRunes)/8
+	// compare against name prefixes around the query's length so typos that
+	// add or drop characters still match
+	for n := len(queryRunes) - maxEdits; n <= len(queryRunes)+maxEdits; n++ {
+		if n < 0 || n > len(nameRunes) {
+			continue
+		}
+		if editDistance(queryRunes, nameRunes[:n]) <= maxEdits {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) searchSubreddits(query *SearchSubreddits) (*SubredditDirectory, error) {
+	sortBy := query.Sort
+	switch sortBy {
+	case "":
+		sortBy = DirectoryByMembers
+	case DirectoryByMembers, DirectoryByActivity, DirectoryByNewest:
+	default:
+		return nil, fmt.Errorf("unknown directory sort %q", query.Sort)
+	}
+
+	postCounts := make(map[string]int)
+	for _, post := range e.posts {
+		if isListed(post, "") {
+			postCounts[post.SubredditName]++
+		}
+	}
+	var entries []SubredditSummary
+	for _, subreddit := range e.subreddits {
+		// private subreddits are only listed for those who can see inside
+		if e.checkCanView(subreddit, query.Username) != nil || !matchesDirectoryQuery(subreddit, query.Query) {
+			continue
+		}
+		entries = append(entries, SubredditSummary{
+			Name:         subreddit.Name,
+			Description:  subreddit.Description,
+			Type:         subreddit.Type,
+			MemberCount:  len(subreddit.Members),
+			PostCount:    postCounts[subreddit.Name],
+			Created:      subreddit.Created,
+			LastActivity: subreddit.LastActivity,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch sortBy {
+		case DirectoryByActivity:
+			if !a.LastActivity.Equal(b.LastActivity) {
+				return a.LastActivity.After(b.LastActivity)
+			}
+		case DirectoryByNewest:
+			if !a.Created.Equal(b.Created) {
+				return a.Created.After(b.Created)
+			}
+		default:
+			if a.MemberCount != b.MemberCount {
+				return a.MemberCount > b.MemberCount
+			}
+		}
+		return a.Name < b.Name
+	})
+
+	start, end := paginate(len(entries), query.Offset, query.Limit)
+	directory := &SubredditDirectory{Subreddits: entries[start:end], Total: len(entries), NextOffset: -1}
+	if end < len(entries) {
+		directory.NextOffset = end
+	}
+	return directory, nil
+}
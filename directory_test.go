@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abc", "abc", 0},
+		{"abc", "abd", 1},
+		{"abc", "acb", 1}, // adjacent swap
+		{"ab", "ba", 1},
+		{"kitten", "sitting", 3},
+		// optimal string alignment does not edit a substring twice
+		{"ca", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesDirectoryQuery(t *testing.T) {
+	subreddit := &Subreddit{Name: "r/Programming", Description: "All about code"}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"prog", true},
+		{"r/PROG", true},
+		{"code", true},         // description
+		{"porg", true},         // swapped letters
+		{"programing", true},   // dropped letter
+		{"progarmming", true},  // one swap in a long query
+		{"prgoramminh", true},  // two typos allowed from eight characters on
+		{"prgoarmmimg", false}, // but not three
+		{"gram", false},        // not a prefix
+		{"rp", false},          // too short for typos
+		{"xyz", false},
+		{"programmingx", true},
+		{"programmingxyz", false},
+	}
+	for _, tt := range tests {
+		if got := matchesDirectoryQuery(subreddit, tt.query); got != tt.want {
+			t.Errorf("matchesDirectoryQuery(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
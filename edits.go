@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// authoredPost looks up a live post written by author.
+func (e *Engine) authoredPost(postID, author string) (*Post, error) {
+	post, exists := e.posts[postID]
+	if !exists || post.RemovedBy != "" || post.Deleted {
+		return nil, fmt.Errorf("post %s does not exist", postID)
+	}
+	if post.Author != author {
+		return nil, fmt.Errorf("post %s was not written by %s", postID, author)
+	}
+	return post, nil
+}
+
+// authoredComment looks up a live comment written by author.
+func (e *Engine) authoredComment(postID, commentID, author string) (*Post, *Comment, error) {
+	post, exists := e.posts[postID]
+	if !exists || post.RemovedBy != "" || post.Deleted {
+		return nil, nil, fmt.Errorf("post %s does not exist", postID)
+	}
+	comment := findComment(post.Comments, commentID)
+	if comment == nil || comment.RemovedBy != "" || comment.Deleted {
+		return nil, nil, fmt.Errorf("comment %s does not exist on post %s", commentID, postID)
+	}
+	if comment.Author != author {
+		return nil, nil, fmt.Errorf("comment %s was not written by %s", commentID, author)
+	}
+	return post, comment, nil
+}
+
+func (e *Engine) editPost(postID, author, content string) error {
+	post, err := e.authoredPost(postID, author)
+	if err != nil {
+		return err
+	}
+	post.Revisions = append(post.Revisions, Revision{Content: post.Content, Written: lastWritten(post.Created, post.Edited)})
+	post.Content = content
+	post.Edited = time.Now()
+	e.indexPost(post)
+	// only users newly mentioned by the edit hear about it
+	if !post.Held {
+		e.deliverPostMentions(post)
+	}
+	e.logUserAction(UserAction{Kind: ActionEditPost, Actor: author, Subreddit: post.SubredditName, PostID: postID, Content: content})
+	return nil
+}
+
+// deletePost hides the post from listings and drops its content and history.
+// The post stays reachable by ID as a [deleted] placeholder so its comments
+// can still be read.
+func (e *Engine) deletePost(postID, author string) error {
+	post, err := e.authoredPost(postID, author)
+	if err != nil {
+		return err
+	}
+	post.Deleted = true
+	post.Content = ""
+	post.Revisions = nil
+	e.index.remove(reportKey(ReportPost, postID, ""))
+	if subreddit, exists := e.subreddits[post.SubredditName]; exists {
+		subreddit.PinnedPosts = remove(subreddit.PinnedPosts, postID)
+	}
+	e.clearReports(ReportPost, postID, "")
+	e.logUserAction(UserAction{Kind: ActionDeletePost, Actor: author, Subreddit: post.SubredditName, PostID: postID})
+	return nil
+}
+
+func (e *Engine) editComment(postID, commentID, author, content string) error {
+	post, comment, err := e.authoredComment(postID, commentID, author)
+	if err != nil {
+		return err
+	}
+	comment.Revisions = append(comment.Revisions, Revision{Content: comment.Content, Written: lastWritten(comment.Created, comment.Edited)})
+	comment.Content = content
+	comment.Edited = time.Now()
+	e.indexComment(postID, comment)
+	if !comment.Held {
+		e.deliverCommentNotifications(post, comment)
+	}
+	e.logUserAction(UserAction{Kind: ActionEditComment, Actor: author, Subreddit: post.SubredditName, PostID: postID, CommentID: commentID, Content: content})
+	return nil
+}
+
+func (e *Engine) deleteComment(postID, commentID, author string) error {
+	post, comment, err := e.authoredComment(postID, commentID, author)
+	if err != nil {
+		return err
+	}
+	// the comment stays in the tree so its replies remain reachable
+	comment.Deleted = true
+	comment.Content = ""
+	comment.Revisions = nil
+	e.index.remove(reportKey(ReportComment, postID, commentID))
+	e.clearReports(ReportComment, postID, commentID)
+	e.logUserAction(UserAction{Kind: ActionDeleteComment, Actor: author, Subreddit: post.SubredditName, PostID: postID, CommentID: commentID})
+	return nil
+}
+
+func (e *Engine) getEditHistory(query *GetEditHistory) (*EditHistory, error) {
+	post, exists := e.posts[query.PostID]
+	if !exists || post.RemovedBy != "" || post.Deleted {
+		return nil, fmt.Errorf("post %s does not exist", query.PostID)
+	}
+	if err := e.checkCanView(e.subreddits[post.SubredditName], query.Username); err != nil {
+		return nil, err
+	}
+	history := &EditHistory{PostID: post.ID, CommentID: query.CommentID}
+	if query.CommentID == "" {
+		history.Revisions = append(history.Revisions, post.Revisions...)
+		history.Revisions = append(history.Revisions, Revision{Content: post.Content, Written: lastWritten(post.Created, post.Edited)})
+		return history, nil
+	}
+	comment := findComment(post.Comments, query.CommentID)
+	if comment == nil || comment.RemovedBy != "" || comment.Deleted {
+		return nil, fmt.Errorf("comment %s does not exist on post %s", query.CommentID, query.PostID)
+	}
+	history.Revisions = append(history.Revisions, comment.Revisions...)
+	history.Revisions = append(history.Revisions, Revision{Content: comment.Content, Written: lastWritten(comment.Created, comment.Edited)})
+	return history, nil
+}
+
+// lastWritten returns when the current content was written.
+func lastWritten(created, edited time.Time) time.Time {
+	if edited.IsZero() {
+		return created
+	}
+	return edited
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"runtime"
 	"sort"
 	"strings"
 	"time"
@@ -10,48 +13,257 @@ import (
 )
 
 type Engine struct {
-	users       map[string]*User
-	subreddits  map[string]*Subreddit
-	posts       map[string]*Post
-	userActions map[string]*UserActions
+	users          map[string]*User
+	subreddits     map[string]*Subreddit
+	posts          map[string]*Post
+	userActions    map[string]*UserActions
+	directMessages map[string]*DirectMessage
+	conversations  map[string]*Conversation
+	lastMessageSeq int                   // last number used for an engine-assigned message ID
+	presence       map[string]*actor.PID // connected users and their clients
+	context        actor.Context
+	reports        map[string]*ReportedItem
+	index          *searchIndex
+	retention      ActionRetention
+	spillFile      *os.File
+	spillWriter    *bufio.Writer
+	droppedActions int
+	spilledActions int
+	lastSweep      time.Time // when every user's actions were last trimmed
 }
 
-func NewEngine() *Engine {
+func NewEngine(retention ActionRetention) *Engine {
 	return &Engine{
-		users:       make(map[string]*User),
-		subreddits:  make(map[string]*Subreddit),
-		posts:       make(map[string]*Post),
-		userActions: make(map[string]*UserActions),
+		users:          make(map[string]*User),
+		subreddits:     make(map[string]*Subreddit),
+		posts:          make(map[string]*Post),
+		userActions:    make(map[string]*UserActions),
+		directMessages: make(map[string]*DirectMessage),
+		conversations:  make(map[string]*Conversation),
+		presence:       make(map[string]*actor.PID),
+		reports:        make(map[string]*ReportedItem),
+		index:          newSearchIndex(),
+		retention:      retention,
 	}
 }
 
 func (e *Engine) Receive(context actor.Context) {
+	span := startReceiveSpan(context)
+	defer span.End()
+	e.context = context
+
 	switch msg := context.Message().(type) {
 	case *actor.Started:
 		fmt.Println("Engine started")
+	case *actor.Stopping:
+		e.closeSpill()
 	case *RegisterUser:
 		e.registerUser(msg.Username)
 	case *CreateSubreddit:
-		e.createSubreddit(msg.Name, msg.Creator)
+		e.respond(context, e.createSubreddit(msg.Name, msg.Creator, msg.Type))
 	case *JoinSubreddit:
-		e.joinSubreddit(msg.SubredditName, msg.Username)
+		e.respond(context, e.joinSubreddit(msg.SubredditName, msg.Username))
 	case *LeaveSubreddit:
 		e.leaveSubreddit(msg.SubredditName, msg.Username)
+	case *AddModerator:
+		e.respond(context, e.addModerator(msg.SubredditName, msg.Moderator, msg.Username))
+	case *RemoveModerator:
+		e.respond(context, e.removeModerator(msg.SubredditName, msg.Moderator, msg.Username))
+	case *RemovePost:
+		e.respond(context, e.removePost(msg.PostID, msg.Moderator, msg.RuleNumber, msg.Reason))
+	case *RemoveComment:
+		e.respond(context, e.removeComment(msg.PostID, msg.CommentID, msg.Moderator, msg.RuleNumber, msg.Reason))
+	case *PinPost:
+		e.respond(context, e.pinPost(msg.PostID, msg.Moderator))
+	case *UnpinPost:
+		e.respond(context, e.unpinPost(msg.PostID, msg.Moderator))
+	case *LockPost:
+		e.respond(context, e.lockPost(msg.PostID, msg.Moderator))
+	case *UnlockPost:
+		e.respond(context, e.unlockPost(msg.PostID, msg.Moderator))
+	case *BanUser:
+		e.respond(context, e.banUser(msg.SubredditName, msg.Moderator, msg.Username, msg.Reason, msg.Duration))
+	case *UnbanUser:
+		e.respond(context, e.unbanUser(msg.SubredditName, msg.Moderator, msg.Username))
+	case *MuteUser:
+		e.respond(context, e.muteUser(msg.SubredditName, msg.Moderator, msg.Username, msg.Reason, msg.Duration))
+	case *UnmuteUser:
+		e.respond(context, e.unmuteUser(msg.SubredditName, msg.Moderator, msg.Username))
+	case *UpdateSubredditInfo:
+		e.respond(context, e.updateSubredditInfo(msg.SubredditName, msg.Moderator, msg.Description, msg.Sidebar))
+	case *AddSubredditRule:
+		e.respond(context, e.addSubredditRule(msg.SubredditName, msg.Moderator, msg.Rule))
+	case *EditSubredditRule:
+		e.respond(context, e.editSubredditRule(msg.SubredditName, msg.Moderator, msg.RuleNumber, msg.Rule))
+	case *RemoveSubredditRule:
+		e.respond(context, e.removeSubredditRule(msg.SubredditName, msg.Moderator, msg.RuleNumber))
+	case *GetSubreddit:
+		if info, err := e.getSubreddit(msg.SubredditName, msg.Username); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(info)
+		}
+	case *SetMembershipPolicy:
+		e.respond(context, e.setMembershipPolicy(msg.SubredditName, msg.Moderator, msg.Policy))
+	case *SetSubredditType:
+		e.respond(context, e.setSubredditType(msg.SubredditName, msg.Moderator, msg.Type))
+	case *ApproveUser:
+		e.respond(context, e.approveUser(msg.SubredditName, msg.Moderator, msg.Username))
+	case *UnapproveUser:
+		e.respond(context, e.unapproveUser(msg.SubredditName, msg.Moderator, msg.Username))
+	case *AddAutoModRule:
+		e.respond(context, e.addAutoModRule(msg.SubredditName, msg.Moderator, msg.Rule))
+	case *RemoveAutoModRule:
+		e.respond(context, e.removeAutoModRule(msg.SubredditName, msg.Moderator, msg.RuleName))
 	case *CreatePost:
-		e.createPost(msg.PostID, msg.SubredditName, msg.Author, msg.Title, msg.Content)
+		e.respond(context, e.createPost(msg.PostID, msg.SubredditName, msg.Author, msg.Title, msg.Content, msg.Flair))
 	case *CreateComment:
 		// e.createComment(msg.PostID, msg.Author, msg.Content)
-		e.createComment(msg.PostID, msg.ParentID, msg.CommentID, msg.Author, msg.Content)
+		e.respond(context, e.createComment(msg.PostID, msg.ParentID, msg.CommentID, msg.Author, msg.Content))
+	case *EditPost:
+		e.respond(context, e.editPost(msg.PostID, msg.Author, msg.Content))
+	case *DeletePost:
+		e.respond(context, e.deletePost(msg.PostID, msg.Author))
+	case *EditComment:
+		e.respond(context, e.editComment(msg.PostID, msg.CommentID, msg.Author, msg.Content))
+	case *DeleteComment:
+		e.respond(context, e.deleteComment(msg.PostID, msg.CommentID, msg.Author))
+	case *RecommendSubreddits:
+		if recommendations, err := e.recommendSubreddits(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(recommendations)
+		}
+	case *SearchSubreddits:
+		if directory, err := e.searchSubreddits(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(directory)
+		}
+	case *Search:
+		if results, err := e.search(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(results)
+		}
+	case *GetEditHistory:
+		if history, err := e.getEditHistory(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(history)
+		}
 	case *Vote:
-		e.vote(msg.PostID, msg.UserID, msg.IsUpvote)
+		e.respond(context, e.vote(msg.PostID, msg.UserID, msg.IsUpvote))
 	case *SendDirectMessage:
-		e.sendDirectMessage(msg.From, msg.To, msg.Content)
+		e.respond(context, e.sendDirectMessage(msg))
+	case *BlockUser:
+		e.respond(context, e.blockUser(msg.Username, msg.Blocked))
+	case *UnblockUser:
+		e.respond(context, e.unblockUser(msg.Username, msg.Blocked))
+	case *GetBlockedUsers:
+		if blocked, err := e.getBlockedUsers(msg.Username); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(blocked)
+		}
+	case *GetPostThread:
+		if thread, err := e.getPostThread(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(thread)
+		}
+	case *Connect:
+		e.respond(context, e.connect(msg.Username, msg.Client))
+	case *Disconnect:
+		e.respond(context, e.disconnect(msg.Username))
+	case *GetInbox:
+		if inbox, err := e.getInbox(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(inbox)
+		}
+	case *GetNotifications:
+		if notifications, err := e.getNotifications(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(notifications)
+		}
+	case *SetNotificationPreferences:
+		e.respond(context, e.setNotificationPreferences(msg.Username, msg.Preferences))
+	case *MarkInboxRead:
+		e.respond(context, e.markInboxRead(msg.Username, msg.ItemIDs))
+	case *MarkAllInboxRead:
+		e.respond(context, e.markAllInboxRead(msg.Username))
+	case *GetConversations:
+		if conversations, err := e.getConversations(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(conversations)
+		}
+	case *GetConversation:
+		if conversation, err := e.getConversation(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(conversation)
+		}
+	case *Report:
+		e.respond(context, e.report(msg))
+	case *GetModQueue:
+		if queue, err := e.getModQueue(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(queue)
+		}
+	case *GetMessageReports:
+		context.Respond(e.getMessageReports(msg))
+	case *ApproveReported:
+		e.respond(context, e.approveReported(msg.Moderator, msg.PostID, msg.CommentID))
 	case *GetFeed:
-		e.getFeed(msg.Username)
+		if listing, err := e.getFeed(msg.Username, msg.Flair, msg.Offset, msg.Limit); err != nil {
+			e.respond(context, err)
+		} else {
+			e.reply(context, listing)
+		}
+	case *GetGlobalListing:
+		if listing, err := e.getGlobalListing(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			e.reply(context, listing)
+		}
+	case *GetHomeFeed:
+		if listing, err := e.getHomeFeed(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			e.reply(context, listing)
+		}
+	case *HidePost:
+		e.respond(context, e.hidePost(msg.PostID, msg.Username))
+	case *UnhidePost:
+		e.respond(context, e.unhidePost(msg.PostID, msg.Username))
+	case *GetSubredditListing:
+		if listing, err := e.getSubredditListing(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(listing)
+		}
+	case *AddPostFlair:
+		e.respond(context, e.addPostFlair(msg.SubredditName, msg.Moderator, msg.Flair))
+	case *RemovePostFlair:
+		e.respond(context, e.removePostFlair(msg.SubredditName, msg.Moderator, msg.Flair))
+	case *SetUserFlair:
+		e.respond(context, e.setUserFlair(msg.SubredditName, msg.SetBy, msg.Username, msg.Flair))
+	case *GetModLog:
+		if modLog, err := e.getModLog(msg); err != nil {
+			e.respond(context, err)
+		} else {
+			context.Respond(modLog)
+		}
+	case *GetUserActivity:
+		context.Respond(e.getUserActivity(msg))
 	case *GetSimulationStats:
 		e.getSimulationStats()
 	case *PrintUserActions:
-		e.printAllUserActions()
+		e.printAllUserActions(msg.JSONLines)
 	case *PrintSubredditPostsAndComments:
 		e.printSubredditPostsAndComments()
 	}
@@ -59,35 +271,64 @@ func (e *Engine) Receive(context actor.Context) {
 
 func (e *Engine) registerUser(username string) {
 	if _, exists := e.users[username]; !exists {
-		e.users[username] = &User{Username: username, Karma: 0}
+		e.users[username] = &User{Username: username, Created: time.Now(), Karma: 0,
+			HiddenPosts: make(map[string]bool), VotedPosts: make(map[string]bool),
+			Notifications: NotificationPreferences{PostReplies: true, CommentReplies: true, Mentions: true}}
 		//fmt.Printf("[REGISTER USER] User registered: %s\n", username)
-		e.logUserAction(username, "[REGISTER USER]  Registerd as new user")
+		e.logUserAction(UserAction{Kind: ActionRegisterUser, Actor: username})
 
 	}
 }
 
-func (e *Engine) createSubreddit(name, creator string) {
-	if _, exists := e.subreddits[name]; !exists {
-		e.subreddits[name] = &Subreddit{Name: name, Creator: creator, Members: []string{creator}}
-		//fmt.Printf("[CREATE SUB] Subreddit created: %s by %s\n", name, creator)
-		log_str := fmt.Sprintf("[CREATE SUB]     Subreddit created: %s by %s", name, creator)
-
-		e.logUserAction(creator, log_str)
+func (e *Engine) createSubreddit(name, creator string, subredditType SubredditType) error {
+	if _, exists := e.subreddits[name]; exists {
+		return fmt.Errorf("subreddit %s already exists", name)
+	}
+	if subredditType == "" {
+		subredditType = SubredditPublic
+	}
+	if !validSubredditType(subredditType) {
+		return fmt.Errorf("unknown subreddit type %q", subredditType)
 	}
+	e.subreddits[name] = &Subreddit{
+		Name:       name,
+		Creator:    creator,
+		Created:    time.Now(),
+		Type:       subredditType,
+		Members:    []string{creator},
+		Moderators: []string{creator},
+		Policy:     MembershipPolicy{PostRequiresMembership: true, CommentRequiresMembership: true},
+		UserFlairs: make(map[string]string),
+		Banned:     make(map[string]*Restriction),
+		Muted:      make(map[string]*Restriction),
+	}
+	//fmt.Printf("[CREATE SUB] Subreddit created: %s by %s\n", name, creator)
+	e.logUserAction(UserAction{Kind: ActionCreateSubreddit, Actor: creator, Subreddit: name, Content: string(subredditType)})
+	return nil
 }
 
-func (e *Engine) joinSubreddit(subredditName, username string) {
-	if subreddit, exists := e.subreddits[subredditName]; exists {
-		if user, userExists := e.users[username]; userExists {
-			if !contains(subreddit.Members, username) {
-				subreddit.Members = append(subreddit.Members, username)
-				user.SubscribedSubreddits = append(user.SubscribedSubreddits, subredditName)
-				//fmt.Printf("[JOIN SUB] %s joined subreddit %s\n", username, subredditName)
-				log_str := fmt.Sprintf("[JOIN SUB]       %s joined subreddit %s", username, subredditName)
-				e.logUserAction(username, log_str)
-			}
-		}
+func (e *Engine) joinSubreddit(subredditName, username string) error {
+	subreddit, exists := e.subreddits[subredditName]
+	if !exists {
+		return fmt.Errorf("subreddit %s does not exist", subredditName)
 	}
+	user, userExists := e.users[username]
+	if !userExists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if ban := e.activeRestriction(subreddit.Banned, username); ban != nil {
+		return fmt.Errorf("%s is banned from %s", username, subredditName)
+	}
+	if err := e.checkCanView(subreddit, username); err != nil {
+		return err
+	}
+	if !contains(subreddit.Members, username) {
+		subreddit.Members = append(subreddit.Members, username)
+		user.SubscribedSubreddits = append(user.SubscribedSubreddits, subredditName)
+		//fmt.Printf("[JOIN SUB] %s joined subreddit %s\n", username, subredditName)
+		e.logUserAction(UserAction{Kind: ActionJoinSubreddit, Actor: username, Subreddit: subredditName})
+	}
+	return nil
 }
 
 func (e *Engine) leaveSubreddit(subredditName, username string) {
@@ -97,58 +338,126 @@ func (e *Engine) leaveSubreddit(subredditName, username string) {
 				subreddit.Members = remove(subreddit.Members, username)
 				user.SubscribedSubreddits = remove(user.SubscribedSubreddits, subredditName)
 				//fmt.Printf("[LEAVE SUB] %s left subreddit %s\n", username, subredditName)
-				log_str := fmt.Sprintf("[LEAVE SUB]      %s left subreddit %s", username, subredditName)
-
-				e.logUserAction(username, log_str)
+				e.logUserAction(UserAction{Kind: ActionLeaveSubreddit, Actor: username, Subreddit: subredditName})
 			}
 		}
 	}
 }
 
-func (e *Engine) createPost(postID, subredditName, author, title, content string) {
-	if _, exists := e.subreddits[subredditName]; exists {
-
-		e.posts[postID] = &Post{ID: postID, SubredditName: subredditName, Author: author, Title: title, Content: content}
-		// by default upvote for post by author when posted & increased karma
-		post := e.posts[postID]
-		post.Upvotes++
-		e.users[post.Author].Karma++
-		// fmt.Printf("[POST] Post created in %s by %s: %s\n", subredditName, author, title)
-		log_str := fmt.Sprintf("[POST]           %s created in %s by %s: %s", postID, subredditName, author, title)
-
-		e.logUserAction(author, log_str)
-		// }
+func (e *Engine) createPost(postID, subredditName, author, title, content, flair string) error {
+	subreddit, exists := e.subreddits[subredditName]
+	if !exists {
+		return fmt.Errorf("subreddit %s does not exist", subredditName)
+	}
+	if _, exists := e.posts[postID]; exists {
+		return fmt.Errorf("post %s already exists", postID)
 	}
+	if _, exists := e.users[author]; !exists {
+		return fmt.Errorf("user %s does not exist", author)
+	}
+	if err := e.checkCanContribute(subreddit, author); err != nil {
+		return err
+	}
+	if err := e.checkCanSubmit(subreddit, author); err != nil {
+		return err
+	}
+	if subreddit.Policy.PostRequiresMembership {
+		if err := e.checkMembership(subreddit, author); err != nil {
+			return err
+		}
+	}
+	if flair != "" && !contains(subreddit.PostFlairs, flair) {
+		return fmt.Errorf("%s has no post flair %q", subredditName, flair)
+	}
+	rule := e.matchAutoModRule(subreddit, author, title, content)
+	e.posts[postID] = &Post{ID: postID, SubredditName: subredditName, Author: author, Title: title, Content: content,
+		Flair: flair, Created: time.Now()}
+	subreddit.LastActivity = e.posts[postID].Created
+	// by default upvote for post by author when posted & increased karma
+	post := e.posts[postID]
+	post.Upvotes++
+	e.users[post.Author].Karma++
+	// fmt.Printf("[POST] Post created in %s by %s: %s\n", subredditName, author, title)
+	e.logUserAction(UserAction{Kind: ActionCreatePost, Actor: author, Subreddit: subredditName, PostID: postID, Title: title})
+	e.indexPost(post)
+	if rule != nil {
+		e.applyAutoModToPost(subreddit, rule, post)
+	}
+	if isListed(post, "") {
+		e.deliverPostMentions(post)
+	}
+	return nil
 }
 
-func (e *Engine) createComment(postID, parentID, commentID, author, content string) {
-	if post, exists := e.posts[postID]; exists {
-		newComment := &Comment{ID: commentID, ParentID: parentID, Author: author, Content: content}
-
-		if parentID == postID {
-			post.Comments = append(post.Comments, newComment)
-			e.users[author].Karma++
-		} else {
-			// fmt.Println(("Adding Child Comment"))
-			e.addChildComment(post.Comments, parentID, author, newComment)
+func (e *Engine) createComment(postID, parentID, commentID, author, content string) error {
+	post, exists := e.posts[postID]
+	if !exists || post.RemovedBy != "" || post.Deleted {
+		return fmt.Errorf("post %s does not exist", postID)
+	}
+	if post.Locked {
+		return fmt.Errorf("post %s is locked", postID)
+	}
+	if findComment(post.Comments, commentID) != nil {
+		return fmt.Errorf("comment %s already exists on post %s", commentID, postID)
+	}
+	if _, exists := e.users[author]; !exists {
+		return fmt.Errorf("user %s does not exist", author)
+	}
+	subreddit := e.subreddits[post.SubredditName]
+	if err := e.checkCanContribute(subreddit, author); err != nil {
+		return err
+	}
+	if err := e.checkCanView(subreddit, author); err != nil {
+		return err
+	}
+	if subreddit.Policy.CommentRequiresMembership {
+		if err := e.checkMembership(subreddit, author); err != nil {
+			return err
+		}
+	}
+	repliedTo := post.Author
+	if parentID != postID {
+		parent := findComment(post.Comments, parentID)
+		if parent == nil {
+			return fmt.Errorf("comment %s does not exist on post %s", parentID, postID)
 		}
+		if parent.RemovedBy != "" || parent.Deleted {
+			return fmt.Errorf("comment %s can no longer be replied to", parentID)
+		}
+		repliedTo = parent.Author
+	}
+	if e.hasBlocked(repliedTo, author) {
+		return fmt.Errorf("%s cannot reply to %s", author, repliedTo)
+	}
+	rule := e.matchAutoModRule(subreddit, author, "", content)
+	newComment := &Comment{ID: commentID, ParentID: parentID, Author: author, Content: content, Created: time.Now()}
+	subreddit.LastActivity = newComment.Created
+
+	if parentID == postID {
+		post.Comments = append(post.Comments, newComment)
+		e.users[author].Karma++
+	} else {
+		// fmt.Println(("Adding Child Comment"))
+		e.addChildComment(post.Comments, parentID, author, newComment)
+	}
 
-		log_str := fmt.Sprintf("[POST Comment]   %s commented on post %s: %s", author, postID, content)
-		e.logUserAction(author, log_str)
+	e.logUserAction(UserAction{Kind: ActionCreateComment, Actor: author, Subreddit: post.SubredditName, PostID: postID, CommentID: commentID, Content: content})
+	e.indexComment(postID, newComment)
+	if rule != nil {
+		e.applyAutoModToComment(subreddit, rule, post, newComment)
+	}
+	if newComment.RemovedBy == "" && !newComment.Held {
+		e.deliverCommentNotifications(post, newComment)
 	}
+	return nil
 }
 
 func (e *Engine) addChildComment(comments []*Comment, parentID string, author string, newComment *Comment) {
 	for _, comment := range comments {
 		if comment.ID == parentID {
-			commentReply := fmt.Sprintf("Reply %d to %s", len(comment.Children)+1, comment.ID)
-			newComment.Content = commentReply
 			comment.Children = append(comment.Children, newComment)
-			// log_str := fmt.Sprintf("[POST Comment]   %s commented on comment %s: %s", author, comment.ID, newComment.Content)
-			// log_str := fmt.Sprintf("[COMMENT REPLY]  %s commented on %s: %s", author, comment.ID, newComment.Content)
 			e.users[author].Karma++
-			log_str := fmt.Sprintf("[COMMENT REPLY]  %s commented on %s: %s", author, comment.ID, commentReply)
-			e.logUserAction(author, log_str)
+			e.logUserAction(UserAction{Kind: ActionCommentReply, Actor: author, CommentID: newComment.ID, ParentID: comment.ID, Content: newComment.Content})
 
 			return
 		}
@@ -156,58 +465,59 @@ func (e *Engine) addChildComment(comments []*Comment, parentID string, author st
 	}
 }
 
-func (e *Engine) vote(postID, userID string, isUpvote bool) {
-	if post, exists := e.posts[postID]; exists {
-		if isUpvote {
-			post.Upvotes++
-			e.users[post.Author].Karma++
-		} else {
-			post.Downvotes++
-			e.users[post.Author].Karma--
-		}
-		voteType := "upvoted"
-		if !isUpvote {
-			voteType = "downvoted"
-		}
-		//fmt.Printf("[VOTE] %s %s post %s\n", userID, voteType, postID)
-		log_str := fmt.Sprintf("[VOTE]           %s %s post %s", userID, voteType, postID)
-		e.logUserAction(userID, log_str)
-
+func (e *Engine) vote(postID, userID string, isUpvote bool) error {
+	post, exists := e.posts[postID]
+	if !exists || post.RemovedBy != "" || post.Deleted {
+		return fmt.Errorf("post %s does not exist", postID)
 	}
-}
-
-func (e *Engine) sendDirectMessage(from, to, content string) {
-	if fromUser, exists := e.users[from]; exists {
-		if toUser, exists := e.users[to]; exists {
-			message := &DirectMessage{From: from, To: to, Content: content}
-			fromUser.SentMessages = append(fromUser.SentMessages, message)
-			toUser.ReceivedMessages = append(toUser.ReceivedMessages, message)
-			//fmt.Printf("[Direct Message] DM sent from %s to %s: %s\n", from, to, content)
-			log_str := fmt.Sprintf("[Direct Message] DM sent to %s: %s", to, content)
-			e.logUserAction(from, log_str)
-		}
+	if _, exists := e.users[userID]; !exists {
+		return fmt.Errorf("user %s does not exist", userID)
 	}
+	subreddit := e.subreddits[post.SubredditName]
+	if ban := e.activeRestriction(subreddit.Banned, userID); ban != nil {
+		return fmt.Errorf("%s is banned from %s", userID, post.SubredditName)
+	}
+	if err := e.checkCanView(subreddit, userID); err != nil {
+		return err
+	}
+	e.users[userID].VotedPosts[postID] = isUpvote
+	if isUpvote {
+		post.Upvotes++
+		e.users[post.Author].Karma++
+	} else {
+		post.Downvotes++
+		e.users[post.Author].Karma--
+	}
+	voteType := "upvoted"
+	if !isUpvote {
+		voteType = "downvoted"
+	}
+	//fmt.Printf("[VOTE] %s %s post %s\n", userID, voteType, postID)
+	e.logUserAction(UserAction{Kind: ActionVote, Actor: userID, Subreddit: post.SubredditName, PostID: postID, VoteType: voteType})
+	return nil
 }
 
-func (e *Engine) getFeed(username string) {
-	if user, exists := e.users[username]; exists {
-		var feed []*Post
-		for _, post := range e.posts {
-			if contains(user.SubscribedSubreddits, post.SubredditName) {
-				feed = append(feed, post)
-			}
-		}
-		//fmt.Printf("[SHOW FEED] Feed for user %s -----\n ", username)
-		log_str := fmt.Sprintf("[SHOW FEED]      Feed for user %s ----- ", username)
-
-		e.logUserAction(username, log_str)
-
-		for _, post := range feed {
-			//fmt.Printf("%s (in %s)\n", post.Title, post.SubredditName)
-			log_str := fmt.Sprintf("                 %s (in %s)", post.Title, post.SubredditName)
-			e.logUserAction(username, log_str)
+func (e *Engine) getFeed(username, flair string, offset, limit int) (*PostListing, error) {
+	user, exists := e.users[username]
+	if !exists {
+		return nil, fmt.Errorf("user %s does not exist", username)
+	}
+	var feed []*Post
+	for _, post := range e.posts {
+		if isListed(post, flair) && contains(user.SubscribedSubreddits, post.SubredditName) &&
+			!contains(user.BlockedUsers, post.Author) && e.checkCanView(e.subreddits[post.SubredditName], username) == nil {
+			feed = append(feed, post)
 		}
 	}
+	sortPosts(feed, SortNew)
+	listing := e.buildListing(feed, offset, limit)
+	var postIDs []string
+	for _, post := range listing.Posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	//fmt.Printf("[SHOW FEED] Feed for user %s -----\n ", username)
+	e.logUserAction(UserAction{Kind: ActionShowFeed, Actor: username, PostIDs: postIDs})
+	return listing, nil
 }
 
 func (e *Engine) getSimulationStats() {
@@ -216,6 +526,22 @@ func (e *Engine) getSimulationStats() {
 	fmt.Printf("Total Subreddits: %d\n", len(e.subreddits))
 	fmt.Printf("Total Posts: %d\n", len(e.posts))
 
+	e.sweepUserActions(time.Now())
+	retained, bytes := 0, 0
+	for _, userActions := range e.userActions {
+		retained += len(userActions.Actions)
+		for _, action := range userActions.Actions {
+			bytes += action.approxSize()
+		}
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Println("\nAction Log:")
+	fmt.Printf("Retained actions: %d (~%.1f KB)\n", retained, float64(bytes)/1024)
+	fmt.Printf("Dropped actions: %d\n", e.droppedActions)
+	fmt.Printf("Spilled actions: %d\n", e.spilledActions)
+	fmt.Printf("Heap in use: %.1f MB\n", float64(mem.HeapInuse)/(1024*1024))
+
 	fmt.Println("\nUser Karma:")
 	var users []string
 	for username := range e.users {
@@ -226,6 +552,26 @@ func (e *Engine) getSimulationStats() {
 		fmt.Printf("%s: %d karma\n", username, e.users[username].Karma)
 	}
 
+	fmt.Printf("Open Reports: %d\n", len(e.reports))
+
+	fmt.Println("\nModeration Log:")
+	var subreddits []string
+	for subredditName := range e.subreddits {
+		subreddits = append(subreddits, subredditName)
+	}
+	sort.Strings(subreddits)
+	for _, subredditName := range subreddits {
+		modLog := e.subreddits[subredditName].ModLog
+		fmt.Printf("%s: %d entries\n", subredditName, len(modLog))
+		for _, entry := range modLog {
+			fmt.Printf("  %s %s %s", entry.Moderator, entry.Action.label(), entry.Target)
+			if entry.Reason != "" {
+				fmt.Printf(" (%s)", entry.Reason)
+			}
+			fmt.Println()
+		}
+	}
+
 	fmt.Println("\nPost Statistics:")
 	var posts []string
 	for postID := range e.posts {
@@ -238,15 +584,133 @@ func (e *Engine) getSimulationStats() {
 			postID, post.Author, post.SubredditName, post.Upvotes, post.Downvotes, len(post.Comments))
 	}
 }
-func (e *Engine) logUserAction(username, action string) {
+func (e *Engine) logUserAction(action UserAction) {
+	username := action.Actor
 	if _, exists := e.userActions[username]; !exists {
 		e.userActions[username] = &UserActions{Username: username, Actions: []UserAction{}}
 	}
-	e.userActions[username].Actions = append(e.userActions[username].Actions, UserAction{
-		Action:    action,
-		Timestamp: time.Now(),
-	})
+	action.Timestamp = time.Now()
+	e.userActions[username].Actions = append(e.userActions[username].Actions, action)
+	if e.retention.enabled() {
+		e.trimUserActions(e.userActions[username], action.Timestamp)
+	}
+	// other users' entries only age out when they log again, so idle users
+	// are swept once per window
+	if e.retention.MaxAge > 0 && action.Timestamp.Sub(e.lastSweep) >= e.retention.MaxAge {
+		e.sweepUserActions(action.Timestamp)
+	}
+}
+
+// sweepUserActions applies the retention policy to every user's actions.
+func (e *Engine) sweepUserActions(now time.Time) {
+	if !e.retention.enabled() {
+		return
+	}
+	for _, userActions := range e.userActions {
+		e.trimUserActions(userActions, now)
+	}
+	e.lastSweep = now
+}
+
+// trimUserActions removes the entries that fall outside the retention policy,
+// spilling them to disk when a spill path is configured.
+func (e *Engine) trimUserActions(userActions *UserActions, now time.Time) {
+	n := e.retention.expired(userActions.Actions, now)
+	if n == 0 {
+		return
+	}
+	if e.retention.SpillPath != "" && e.spill(userActions.Actions[:n]) {
+		e.spilledActions += n
+	} else {
+		e.droppedActions += n
+	}
+	userActions.Actions = userActions.Actions[n:]
+}
+
+func (e *Engine) spill(actions []UserAction) bool {
+	if e.spillWriter == nil {
+		file, err := os.OpenFile(e.retention.SpillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Printf("Failed to open action spill file %s: %v\n", e.retention.SpillPath, err)
+			e.retention.SpillPath = ""
+			return false
+		}
+		e.spillFile = file
+		e.spillWriter = bufio.NewWriter(file)
+	}
+	if err := writeActionsJSONLines(e.spillWriter, actions); err != nil {
+		fmt.Printf("Failed to spill actions: %v\n", err)
+		return false
+	}
+	return true
+}
+
+func (e *Engine) closeSpill() {
+	if e.spillWriter == nil {
+		return
+	}
+	e.spillWriter.Flush()
+	e.spillFile.Close()
+	e.spillWriter = nil
 }
+func (e *Engine) getUserActivity(query *GetUserActivity) *UserActivity {
+	activity := &UserActivity{UserActions: UserActions{Username: query.Username}, NextOffset: -1}
+	userActions, exists := e.userActions[query.Username]
+	if !exists {
+		return activity
+	}
+	if e.retention.enabled() {
+		e.trimUserActions(userActions, time.Now())
+	}
+
+	var matched []UserAction
+	for _, action := range userActions.Actions {
+		if len(query.Kinds) > 0 && !containsKind(query.Kinds, action.Kind) {
+			continue
+		}
+		if !query.Since.IsZero() && action.Timestamp.Before(query.Since) {
+			continue
+		}
+		if !query.Until.IsZero() && action.Timestamp.After(query.Until) {
+			continue
+		}
+		matched = append(matched, action)
+	}
+	activity.Total = len(matched)
+
+	start, end := paginate(len(matched), query.Offset, query.Limit)
+	activity.Actions = matched[start:end]
+	if end < len(matched) {
+		activity.NextOffset = end
+	}
+	return activity
+}
+
+// paginate clamps offset and limit to a slice of length n, with a zero limit
+// meaning everything from offset on.
+func paginate(n, offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > n {
+		offset = n
+	}
+	end := n
+	if limit > 0 && offset+limit < n {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+func containsKind(kinds []ActionKind, kind ActionKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
 func contains(slice []string, item string) bool {
 	for _, a := range slice {
 		if a == item {
@@ -265,27 +729,41 @@ func remove(slice []string, item string) []string {
 	return slice
 }
 
-func (e *Engine) printAllUserActions() {
+func (e *Engine) printAllUserActions(jsonLines bool) {
+	e.sweepUserActions(time.Now())
+	if jsonLines {
+		for _, userActions := range e.userActions {
+			if err := writeActionsJSONLines(os.Stdout, userActions.Actions); err != nil {
+				fmt.Printf("Failed to write actions for %s: %v\n", userActions.Username, err)
+			}
+		}
+		return
+	}
 	fmt.Println("------- Printing User Actions --------")
 	for _, userActions := range e.userActions {
 		fmt.Printf("\n%s Actions:\n", userActions.Username)
 		for _, action := range userActions.Actions {
 			// fmt.Printf("- %s: %s\n", action.Timestamp.Format(time.RFC3339), action.Action)
-			fmt.Printf("%s\n", action.Action)
+			fmt.Printf("%s\n", action)
 		}
 	}
 }
 
 func (e *Engine) printSubredditPostsAndComments() {
 	fmt.Println("\n--- Subreddit-wise Posts and Comments ---")
-	for subredditName := range e.subreddits {
+	for subredditName, subreddit := range e.subreddits {
 		fmt.Printf("\nSubreddit: %s", subredditName)
 		subredditPosts := 0
-		for _, post := range e.posts {
-			if post.SubredditName == subredditName {
+		for _, post := range e.subredditPosts(subreddit, SortNew) {
+			if post.RemovedBy == "" {
 				subredditPosts++
-				fmt.Printf("\n>Post %d: %s by %s\n", subredditPosts, post.Title, post.Author)
-				fmt.Printf(" Content: %s\n", post.Content)
+				author, content := post.Author+flairLabel(subreddit.UserFlairs[post.Author]), post.Content
+				if post.Deleted {
+					author, content = "[deleted]", "[deleted]"
+				}
+				fmt.Printf("\n>Post %d: %s%s by %s%s\n", subredditPosts, post.Title, flairLabel(post.Flair),
+					author, postMarkers(subreddit, post))
+				fmt.Printf(" Content: %s\n", content)
 				fmt.Printf(" Upvotes: %d | Downvotes: %d\n", post.Upvotes, post.Downvotes)
 				if len(post.Comments) > 0 {
 					fmt.Println(" Comments:")
@@ -298,17 +776,63 @@ func (e *Engine) printSubredditPostsAndComments() {
 		}
 		if subredditPosts == 0 {
 			fmt.Println("\nNo posts in this subreddit yet.")
-			fmt.Printf("\n\n-> Summary:\n Total %d Posts.\n Total %d members.\n\n", subredditPosts, len(e.subreddits[subredditName].Members))
+			fmt.Printf("\n\n-> Summary:\n Total %d Posts.\n Total %d members.\n", subredditPosts, len(subreddit.Members))
 		} else {
-			fmt.Printf("\n\n-> Summary:\n Total %d Posts.\n Total %d members.\n\n", subredditPosts, len(e.subreddits[subredditName].Members))
+			fmt.Printf("\n\n-> Summary:\n Total %d Posts.\n Total %d members.\n", subredditPosts, len(subreddit.Members))
 		}
+		fmt.Printf(" Type: %s\n", subreddit.Type)
+		if subreddit.Description != "" {
+			fmt.Printf(" Description: %s\n", subreddit.Description)
+		}
+		for i, rule := range subreddit.Rules {
+			fmt.Printf(" Rule %d: %s\n", i+1, rule.Title)
+		}
+		fmt.Printf(" Moderators: %s\n\n", strings.Join(subreddit.Moderators, ", "))
+	}
+}
+
+// flairLabel renders a flair next to a title or username.
+func flairLabel(flair string) string {
+	if flair == "" {
+		return ""
 	}
+	return " {" + flair + "}"
+}
+
+// contentMarkers renders moderation state shown after a post or comment.
+func contentMarkers(held bool, tags []string) string {
+	markers := ""
+	for _, tag := range tags {
+		markers += " [" + tag + "]"
+	}
+	if held {
+		markers += " [held for review]"
+	}
+	return markers
+}
+
+// postMarkers adds a post's pinned and locked state to its content markers.
+func postMarkers(subreddit *Subreddit, post *Post) string {
+	markers := ""
+	if contains(subreddit.PinnedPosts, post.ID) {
+		markers += " [pinned]"
+	}
+	if post.Locked {
+		markers += " [locked]"
+	}
+	return markers + contentMarkers(post.Held, post.Tags)
 }
 
 func (e *Engine) printComments(comments []*Comment, depth int) {
 	for _, comment := range comments {
 		indent := strings.Repeat("  ", depth)
-		fmt.Printf("%s- %s: %s\n", indent, comment.Author, comment.Content)
+		if comment.RemovedBy != "" {
+			fmt.Printf("%s- [removed]\n", indent)
+		} else if comment.Deleted {
+			fmt.Printf("%s- [deleted]\n", indent)
+		} else {
+			fmt.Printf("%s- %s: %s%s\n", indent, comment.Author, comment.Content, contentMarkers(comment.Held, comment.Tags))
+		}
 		if len(comment.Children) > 0 {
 			e.printComments(comment.Children, depth+1)
 		}
@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+// newTestEngine returns an engine with users alice and bob, both members of
+// r/test, and a post by alice.
+func newTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	e := NewEngine(ActionRetention{})
+	e.registerUser("alice")
+	e.registerUser("bob")
+	if err := e.createSubreddit("r/test", "alice", SubredditPublic); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.joinSubreddit("r/test", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createPost("Post 1", "r/test", "alice", "Hello", "First post", ""); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		n, offset, limit int
+		start, end       int
+	}{
+		{10, 0, 0, 0, 10},
+		{10, 2, 3, 2, 5},
+		{10, 7, 3, 7, 10},
+		{10, 8, 5, 8, 10},
+		{10, 10, 5, 10, 10},
+		{10, 12, 5, 10, 10},
+		{10, -1, 3, 0, 3},
+		{0, 0, 5, 0, 0},
+	}
+	for _, tt := range tests {
+		start, end := paginate(tt.n, tt.offset, tt.limit)
+		if start != tt.start || end != tt.end {
+			t.Errorf("paginate(%d, %d, %d) = %d, %d, want %d, %d", tt.n, tt.offset, tt.limit, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestCreatePostRejectsTakenID(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "bob", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createPost("Post 1", "r/test", "bob", "Mine now", "Replaced", ""); err == nil {
+		t.Fatal("a second post with a taken ID was accepted")
+	}
+	post := e.posts["Post 1"]
+	if post.Author != "alice" || len(post.Comments) != 1 {
+		t.Fatalf("original post was replaced: author %s with %d comments", post.Author, len(post.Comments))
+	}
+}
+
+func TestNonMembersCannotPost(t *testing.T) {
+	e := newTestEngine(t)
+	e.registerUser("carol")
+	if err := e.createPost("Post 2", "r/test", "carol", "Hi", "Drive-by", ""); err == nil {
+		t.Fatal("a non-member posted")
+	}
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "carol", "Drive-by"); err == nil {
+		t.Fatal("a non-member commented")
+	}
+	if err := e.joinSubreddit("r/test", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createPost("Post 2", "r/test", "carol", "Hi", "Member now", ""); err != nil {
+		t.Fatalf("member cannot post: %v", err)
+	}
+	if err := e.setSubredditType("r/test", "alice", SubredditRestricted); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createPost("Post 3", "r/test", "carol", "Hi", "Not approved", ""); err == nil {
+		t.Fatal("an unapproved member posted in a restricted subreddit")
+	}
+}
+
+func TestCreateCommentRejectsTakenID(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "bob", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createComment("Post 1", "Comment 1", "Comment 2", "alice", "reply"); err != nil {
+		t.Fatal(err)
+	}
+	for _, parentID := range []string{"Post 1", "Comment 1"} {
+		if err := e.createComment("Post 1", parentID, "Comment 2", "bob", "again"); err == nil {
+			t.Errorf("a comment with a taken ID was accepted under %s", parentID)
+		}
+	}
+	comment := findComment(e.posts["Post 1"].Comments, "Comment 2")
+	if comment.Author != "alice" || len(e.posts["Post 1"].Comments) != 1 {
+		t.Fatalf("original comment was shadowed: author %s", comment.Author)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import "fmt"
+
+func (e *Engine) addPostFlair(subredditName, moderator, flair string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if flair == "" {
+		return fmt.Errorf("flair cannot be empty")
+	}
+	if contains(subreddit.PostFlairs, flair) {
+		return fmt.Errorf("%s already has post flair %q", subredditName, flair)
+	}
+	subreddit.PostFlairs = append(subreddit.PostFlairs, flair)
+	e.logModAction(subreddit, UserAction{Kind: ActionAddPostFlair, Actor: moderator, Subreddit: subredditName, Content: flair})
+	return nil
+}
+
+// removePostFlair stops a flair from being offered; posts already carrying
+// it keep it.
+func (e *Engine) removePostFlair(subredditName, moderator, flair string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if !contains(subreddit.PostFlairs, flair) {
+		return fmt.Errorf("%s has no post flair %q", subredditName, flair)
+	}
+	subreddit.PostFlairs = remove(subreddit.PostFlairs, flair)
+	e.logModAction(subreddit, UserAction{Kind: ActionRemovePostFlair, Actor: moderator, Subreddit: subredditName, Content: flair})
+	return nil
+}
+
+func (e *Engine) setUserFlair(subredditName, setBy, username, flair string) error {
+	subreddit, exists := e.subreddits[subredditName]
+	if !exists {
+		return fmt.Errorf("subreddit %s does not exist", subredditName)
+	}
+	if _, exists := e.users[username]; !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	byModerator := setBy != username
+	if byModerator && !e.isModerator(subreddit, setBy) {
+		return fmt.Errorf("%s is not a moderator of %s", setBy, subredditName)
+	}
+	if flair == "" {
+		delete(subreddit.UserFlairs, username)
+	} else {
+		subreddit.UserFlairs[username] = flair
+	}
+	action := UserAction{Kind: ActionSetUserFlair, Actor: setBy, Subreddit: subredditName, TargetUser: username, Content: flair}
+	if byModerator {
+		e.logModAction(subreddit, action)
+	} else {
+		e.logUserAction(action)
+	}
+	return nil
+}
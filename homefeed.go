@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// homeFeedRecommendedSubreddits is how many recommended subreddits the home
+// feed draws posts from.
+const homeFeedRecommendedSubreddits = 5
+
+// unseenPosts returns the listed posts of the given subreddits that the user
+// can view and has neither hidden nor voted on, best first.
+func (e *Engine) unseenPosts(user *User, subredditNames map[string]bool) []*Post {
+	var posts []*Post
+	for _, post := range e.posts {
+		if !subredditNames[post.SubredditName] || !isListed(post, "") || user.HiddenPosts[post.ID] ||
+			contains(user.BlockedUsers, post.Author) {
+			continue
+		}
+		if _, voted := user.VotedPosts[post.ID]; voted {
+			continue
+		}
+		if e.checkCanView(e.subreddits[post.SubredditName], user.Username) == nil {
+			posts = append(posts, post)
+		}
+	}
+	sortPosts(posts, SortHot)
+	return posts
+}
+
+// getHomeFeed blends the user's subscriptions with posts from recommended
+// subreddits so that roughly RecommendedShare of every page is recommended.
+// Either source fills in when the other runs out.
+func (e *Engine) getHomeFeed(query *GetHomeFeed) (*PostListing, error) {
+	user, exists := e.users[query.Username]
+	if !exists {
+		return nil, fmt.Errorf("user %s does not exist", query.Username)
+	}
+	if query.RecommendedShare < 0 || query.RecommendedShare > 1 {
+		return nil, fmt.Errorf("recommended share %v is not between 0 and 1", query.RecommendedShare)
+	}
+
+	subscribed := make(map[string]bool)
+	for _, subredditName := range user.SubscribedSubreddits {
+		subscribed[subredditName] = true
+	}
+	related := make(map[string]bool)
+	if query.RecommendedShare > 0 {
+		recommendations, err := e.recommendSubreddits(&RecommendSubreddits{Username: user.Username, Limit: homeFeedRecommendedSubreddits})
+		if err != nil {
+			return nil, err
+		}
+		for _, recommendation := range recommendations.Subreddits {
+			related[recommendation.Name] = true
+		}
+	}
+	fromSubscriptions := e.unseenPosts(user, subscribed)
+	fromRecommendations := e.unseenPosts(user, related)
+
+	seen := make(map[string]bool)
+	var feed []*Post
+	recommended := 0
+	for len(fromSubscriptions) > 0 || len(fromRecommendations) > 0 {
+		var post *Post
+		wantRecommended := float64(recommended) < math.Floor(query.RecommendedShare*float64(len(feed)+1))
+		if len(fromRecommendations) > 0 && (wantRecommended || len(fromSubscriptions) == 0) {
+			post, fromRecommendations = fromRecommendations[0], fromRecommendations[1:]
+			recommended++
+		} else {
+			post, fromSubscriptions = fromSubscriptions[0], fromSubscriptions[1:]
+		}
+		if !seen[post.ID] {
+			seen[post.ID] = true
+			feed = append(feed, post)
+		}
+	}
+
+	listing := e.buildListing(feed, query.Offset, query.Limit)
+	var postIDs []string
+	for _, post := range listing.Posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	e.logUserAction(UserAction{Kind: ActionShowFeed, Actor: user.Username, PostIDs: postIDs})
+	return listing, nil
+}
+
+func (e *Engine) hidePost(postID, username string) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if _, exists := e.posts[postID]; !exists {
+		return fmt.Errorf("post %s does not exist", postID)
+	}
+	user.HiddenPosts[postID] = true
+	return nil
+}
+
+func (e *Engine) unhidePost(postID, username string) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if !user.HiddenPosts[postID] {
+		return fmt.Errorf("post %s is not hidden by %s", postID, username)
+	}
+	delete(user.HiddenPosts, postID)
+	return nil
+}
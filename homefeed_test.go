@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestHomeFeedSkipsHiddenAndVotedPosts(t *testing.T) {
+	e := newTestEngine(t)
+	e.registerUser("carol")
+	e.registerUser("dave")
+	if err := e.createSubreddit("r/other", "carol", SubredditPublic); err != nil {
+		t.Fatal(err)
+	}
+	// dave's memberships get r/other recommended to bob
+	for _, subredditName := range []string{"r/test", "r/other"} {
+		if err := e.joinSubreddit(subredditName, "dave"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, postID := range []string{"Post 2", "Post 3", "Post 4"} {
+		if err := e.createPost(postID, "r/other", "carol", postID, "Recommended", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.createPost("Post 5", "r/test", "alice", "Post 5", "Subscribed", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, postID := range []string{"Post 2", "Post 5"} {
+		if err := e.hidePost(postID, "bob"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.vote("Post 3", "bob", true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, share := range []float64{0.5, 1} {
+		feed, err := e.getHomeFeed(&GetHomeFeed{Username: "bob", RecommendedShare: share})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]bool)
+		for _, post := range feed.Posts {
+			got[post.ID] = true
+		}
+		if !got["Post 1"] || !got["Post 4"] || len(got) != 2 {
+			t.Errorf("share %v: feed has %v, want Post 1 and Post 4", share, got)
+		}
+	}
+}
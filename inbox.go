@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mentionPattern finds u/name mentions. Spaces in usernames are written as
+// underscores, so "User 3" is mentioned as u/User_3.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w/])u/([\w-]+)`)
+
+// mentionedUsers returns the existing users mentioned in text, in order of
+// first mention.
+func (e *Engine) mentionedUsers(text string) []string {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.ReplaceAll(match[1], "_", " ")
+		if _, exists := e.users[username]; exists && !contains(usernames, username) {
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+// deliverToInbox adds item to the user's inbox unless they turned its kind
+// off or already have it. Users are not notified of their own actions or
+// those of users they blocked.
+func (e *Engine) deliverToInbox(username string, item *InboxItem) {
+	user, exists := e.users[username]
+	if !exists || username == item.From || contains(user.BlockedUsers, item.From) || !user.Notifications.allows(item.Kind) {
+		return
+	}
+	for _, delivered := range user.Inbox {
+		if delivered.ID == item.ID {
+			return
+		}
+	}
+	item.Created = time.Now()
+	user.Inbox = append(user.Inbox, item)
+	e.push(user, item)
+}
+
+func (e *Engine) deliverMessage(message *DirectMessage) {
+	e.deliverToInbox(message.To, &InboxItem{ID: "message:" + message.ID, Kind: InboxMessage, From: message.From,
+		MessageID: message.ID, Content: message.Content, message: message})
+}
+
+// deliverCommentNotifications tells the author of the post or comment that was
+// replied to, and anyone mentioned in the new comment.
+func (e *Engine) deliverCommentNotifications(post *Post, comment *Comment) {
+	key := post.ID + "/" + comment.ID
+	repliedTo := post.Author
+	kind := InboxPostReply
+	if comment.ParentID != post.ID {
+		parent := findComment(post.Comments, comment.ParentID)
+		if parent == nil {
+			return
+		}
+		repliedTo = parent.Author
+		kind = InboxCommentReply
+	}
+	e.deliverToInbox(repliedTo, &InboxItem{ID: "reply:" + key, Kind: kind, From: comment.Author,
+		PostID: post.ID, CommentID: comment.ID, Content: comment.Content})
+	for _, username := range e.mentionedUsers(comment.Content) {
+		// a reply notification already covers mentioning the replied-to user,
+		// unless they turned those off
+		if username != repliedTo || !e.users[repliedTo].Notifications.allows(kind) {
+			e.deliverToInbox(username, &InboxItem{ID: "mention:" + key, Kind: InboxMention, From: comment.Author,
+				PostID: post.ID, CommentID: comment.ID, Content: comment.Content})
+		}
+	}
+}
+
+func (e *Engine) deliverPostMentions(post *Post) {
+	for _, username := range e.mentionedUsers(post.Title + "\n" + post.Content) {
+		e.deliverToInbox(username, &InboxItem{ID: "mention:" + post.ID, Kind: InboxMention, From: post.Author,
+			PostID: post.ID, Content: post.Title})
+	}
+}
+
+// inboxView copies an inbox item for sending out of the engine.
+func inboxView(item *InboxItem) InboxItem {
+	copied := *item
+	copied.Read = item.isRead()
+	copied.message = nil
+	return copied
+}
+
+// inboxItems copies the user's inbox items that pass include, newest first.
+func inboxItems(user *User, unreadOnly bool, include func(*InboxItem) bool) []InboxItem {
+	var items []InboxItem
+	// the inbox is kept in delivery order
+	for i := len(user.Inbox) - 1; i >= 0; i-- {
+		item := user.Inbox[i]
+		if (unreadOnly && item.isRead()) || !include(item) {
+			continue
+		}
+		items = append(items, inboxView(item))
+	}
+	return items
+}
+
+func (e *Engine) getInbox(query *GetInbox) (*Inbox, error) {
+	user, exists := e.users[query.Username]
+	if !exists {
+		return nil, fmt.Errorf("user %s does not exist", query.Username)
+	}
+	inbox := &Inbox{Unread: make(map[InboxKind]int), NextOffset: -1}
+	for _, item := range user.Inbox {
+		if !item.isRead() {
+			inbox.Unread[item.Kind]++
+			inbox.TotalUnread++
+		}
+	}
+	items := inboxItems(user, query.UnreadOnly, func(item *InboxItem) bool {
+		return query.Kind == "" || item.Kind == query.Kind
+	})
+	start, end := paginate(len(items), query.Offset, query.Limit)
+	inbox.Items = items[start:end]
+	inbox.Total = len(items)
+	if end < len(items) {
+		inbox.NextOffset = end
+	}
+	return inbox, nil
+}
+
+func (e *Engine) getNotifications(query *GetNotifications) (*Notifications, error) {
+	user, exists := e.users[query.Username]
+	if !exists {
+		return nil, fmt.Errorf("user %s does not exist", query.Username)
+	}
+	notifications := &Notifications{Preferences: user.Notifications, NextOffset: -1}
+	isNotification := func(item *InboxItem) bool { return item.Kind != InboxMessage }
+	for _, item := range user.Inbox {
+		if isNotification(item) && !item.isRead() {
+			notifications.Unread++
+		}
+	}
+	items := inboxItems(user, query.UnreadOnly, isNotification)
+	start, end := paginate(len(items), query.Offset, query.Limit)
+	notifications.Items = items[start:end]
+	notifications.Total = len(items)
+	if end < len(items) {
+		notifications.NextOffset = end
+	}
+	return notifications, nil
+}
+
+func (e *Engine) setNotificationPreferences(username string, preferences NotificationPreferences) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	user.Notifications = preferences
+	return nil
+}
+
+func (e *Engine) markInboxRead(username string, itemIDs []string) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	items := make(map[string]*InboxItem)
+	for _, item := range user.Inbox {
+		items[item.ID] = item
+	}
+	for _, itemID := range itemIDs {
+		if _, exists := items[itemID]; !exists {
+			return fmt.Errorf("%s has no inbox item %s", username, itemID)
+		}
+	}
+	for _, itemID := range itemIDs {
+		items[itemID].markRead()
+	}
+	return nil
+}
+
+func (e *Engine) markAllInboxRead(username string) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	for _, item := range user.Inbox {
+		item.markRead()
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestNestedReplyNotifications(t *testing.T) {
+	e := newTestEngine(t)
+	e.registerUser("carol")
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "bob", "top level"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createComment("Post 1", "Comment 1", "Comment 2", "alice", "agreed, cc u/carol"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		username string
+		kind     InboxKind
+		itemID   string
+	}{
+		{"alice", InboxPostReply, "reply:Post 1/Comment 1"},
+		{"bob", InboxCommentReply, "reply:Post 1/Comment 2"},
+		{"carol", InboxMention, "mention:Post 1/Comment 2"},
+	}
+	for _, tt := range tests {
+		notifications, err := e.getNotifications(&GetNotifications{Username: tt.username})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notifications.Items) != 1 || notifications.Items[0].ID != tt.itemID || notifications.Items[0].Kind != tt.kind {
+			t.Errorf("%s's notifications = %+v, want one %s %s", tt.username, notifications.Items, tt.kind, tt.itemID)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// popularSubredditShare caps how much of each page of the popular listing a
+// single subreddit may fill.
+const popularSubredditShare = 0.25
+
+// defaultPageSize is the page size the popular listing is capped over when a
+// request does not set a limit.
+const defaultPageSize = 25
+
+func (e *Engine) summarizePost(post *Post) PostSummary {
+	summary := PostSummary{
+		ID:            post.ID,
+		SubredditName: post.SubredditName,
+		Author:        post.Author,
+		Title:         post.Title,
+		Flair:         post.Flair,
+		Upvotes:       post.Upvotes,
+		Downvotes:     post.Downvotes,
+		CommentCount:  len(post.Comments),
+		Locked:        post.Locked,
+		Created:       post.Created,
+	}
+	if subreddit, exists := e.subreddits[post.SubredditName]; exists {
+		summary.AuthorFlair = subreddit.UserFlairs[post.Author]
+		summary.Pinned = contains(subreddit.PinnedPosts, post.ID)
+	}
+	return summary
+}
+
+// isListed reports whether a post shows up in listings: it must not be
+// removed, deleted or held, and must match flair when one is given.
+func isListed(post *Post, flair string) bool {
+	return post.RemovedBy == "" && !post.Deleted && !post.Held && (flair == "" || post.Flair == flair)
+}
+
+// hotScore ranks posts by net votes on a log scale, decayed by age so that a
+// post needs ten times the votes to outrank one 12.5 hours newer.
+func hotScore(post *Post) float64 {
+	net := post.Upvotes - post.Downvotes
+	order := math.Log10(math.Max(math.Abs(float64(net)), 1))
+	if net < 0 {
+		order = -order
+	}
+	return order + float64(post.Created.Unix())/45000
+}
+
+func validListingSort(sortBy ListingSort) bool {
+	switch sortBy {
+	case "", SortNew, SortHot, SortTop:
+		return true
+	}
+	return false
+}
+
+// sortPosts orders posts for a listing; an empty sort means newest first.
+// Ties go to the lower post ID.
+func sortPosts(posts []*Post, sortBy ListingSort) {
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i], posts[j]
+		switch sortBy {
+		case SortHot:
+			if scoreA, scoreB := hotScore(a), hotScore(b); scoreA != scoreB {
+				return scoreA > scoreB
+			}
+		case SortTop:
+			if netA, netB := a.Upvotes-a.Downvotes, b.Upvotes-b.Downvotes; netA != netB {
+				return netA > netB
+			}
+		default:
+			if !a.Created.Equal(b.Created) {
+				return a.Created.After(b.Created)
+			}
+		}
+		return a.ID < b.ID
+	})
+}
+
+// subredditPosts returns every post of the subreddit in listing order: pinned
+// posts in the order they were pinned, then the rest in sortBy order.
+func (e *Engine) subredditPosts(subreddit *Subreddit, sortBy ListingSort) []*Post {
+	var pinned, rest []*Post
+	for _, postID := range subreddit.PinnedPosts {
+		pinned = append(pinned, e.posts[postID])
+	}
+	for _, post := range e.posts {
+		if post.SubredditName == subreddit.Name && !contains(subreddit.PinnedPosts, post.ID) {
+			rest = append(rest, post)
+		}
+	}
+	sortPosts(rest, sortBy)
+	return append(pinned, rest...)
+}
+
+// buildListing returns the requested page of posts, which are already in
+// listing order.
+func (e *Engine) buildListing(posts []*Post, offset, limit int) *PostListing {
+	start, end := paginate(len(posts), offset, limit)
+	listing := &PostListing{Total: len(posts), NextOffset: -1}
+	for _, post := range posts[start:end] {
+		listing.Posts = append(listing.Posts, e.summarizePost(post))
+	}
+	if end < len(posts) {
+		listing.NextOffset = end
+	}
+	return listing
+}
+
+func (e *Engine) getSubredditListing(query *GetSubredditListing) (*PostListing, error) {
+	subreddit, exists := e.subreddits[query.SubredditName]
+	if !exists {
+		return nil, fmt.Errorf("subreddit %s does not exist", query.SubredditName)
+	}
+	if err := e.checkCanView(subreddit, query.Username); err != nil {
+		return nil, err
+	}
+	if !validListingSort(query.Sort) {
+		return nil, fmt.Errorf("unknown listing sort %q", query.Sort)
+	}
+	var posts []*Post
+	for _, post := range e.subredditPosts(subreddit, query.Sort) {
+		if isListed(post, query.Flair) && !e.hasBlocked(query.Username, post.Author) {
+			posts = append(posts, post)
+		}
+	}
+	return e.buildListing(posts, query.Offset, query.Limit), nil
+}
+
+// capPerSubreddit reorders posts so that no page of pageSize posts holds more
+// than maxPerSubreddit from one subreddit. Posts over the cap move to later
+// pages; a page is only topped up past the cap once nothing else is left.
+func capPerSubreddit(posts []*Post, pageSize, maxPerSubreddit int) []*Post {
+	var capped []*Post
+	for len(posts) > 0 {
+		counts := make(map[string]int)
+		var page, deferred []*Post
+		for _, post := range posts {
+			if len(page) < pageSize && counts[post.SubredditName] < maxPerSubreddit {
+				page = append(page, post)
+				counts[post.SubredditName]++
+			} else {
+				deferred = append(deferred, post)
+			}
+		}
+		for len(page) < pageSize && len(deferred) > 0 {
+			page, deferred = append(page, deferred[0]), deferred[1:]
+		}
+		capped = append(capped, page...)
+		posts = deferred
+	}
+	return capped
+}
+
+// getGlobalListing lists posts from every subreddit that is not private. The
+// popular listing additionally caps each subreddit's share of a page.
+func (e *Engine) getGlobalListing(query *GetGlobalListing) (*PostListing, error) {
+	if query.Listing != ListingAll && query.Listing != ListingPopular {
+		return nil, fmt.Errorf("unknown global listing %q", query.Listing)
+	}
+	if !validListingSort(query.Sort) {
+		return nil, fmt.Errorf("unknown listing sort %q", query.Sort)
+	}
+	var posts []*Post
+	for _, post := range e.posts {
+		if isListed(post, query.Flair) && e.subreddits[post.SubredditName].Type != SubredditPrivate &&
+			!e.hasBlocked(query.Username, post.Author) {
+			posts = append(posts, post)
+		}
+	}
+	sortPosts(posts, query.Sort)
+	if query.Listing == ListingPopular {
+		pageSize := query.Limit
+		if pageSize <= 0 {
+			pageSize = defaultPageSize
+		} else if query.Offset%pageSize != 0 {
+			// pages are capped one at a time, so a page can only start where
+			// the one before it ended
+			return nil, fmt.Errorf("offset %d is not a multiple of the page size %d", query.Offset, pageSize)
+		}
+		maxPerSubreddit := max(1, int(popularSubredditShare*float64(pageSize)))
+		posts = capPerSubreddit(posts, pageSize, maxPerSubreddit)
+	}
+	return e.buildListing(posts, query.Offset, query.Limit), nil
+}
+
+func (e *Engine) getPostThread(query *GetPostThread) (*PostThread, error) {
+	post, exists := e.posts[query.PostID]
+	// deleted posts stay reachable so that their comments can still be read
+	if !exists || post.RemovedBy != "" || post.Held || e.hasBlocked(query.Username, post.Author) {
+		return nil, fmt.Errorf("post %s does not exist", query.PostID)
+	}
+	if err := e.checkCanView(e.subreddits[post.SubredditName], query.Username); err != nil {
+		return nil, err
+	}
+	thread := &PostThread{Post: e.summarizePost(post), Content: post.Content, Comments: e.commentViews(post.Comments, query.Username)}
+	if post.Deleted {
+		thread.Post.Author, thread.Post.AuthorFlair, thread.Content = "[deleted]", "", "[deleted]"
+	}
+	return thread, nil
+}
+
+func (e *Engine) commentViews(comments []*Comment, viewer string) []CommentView {
+	var views []CommentView
+	for _, comment := range comments {
+		view := CommentView{ID: comment.ID, Author: comment.Author, Content: comment.Content, Created: comment.Created, Edited: comment.Edited}
+		switch {
+		case comment.RemovedBy != "" || comment.Held:
+			view.Author, view.Content = "", "[removed]"
+		case comment.Deleted:
+			view.Author, view.Content = "", "[deleted]"
+		case e.hasBlocked(viewer, comment.Author):
+			view.Author, view.Content = "", "[blocked]"
+		}
+		view.Replies = e.commentViews(comment.Children, viewer)
+		views = append(views, view)
+	}
+	return views
+}
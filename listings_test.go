@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCapPerSubreddit(t *testing.T) {
+	tests := []struct {
+		name                      string
+		posts                     []string // "<subreddit><n>"
+		pageSize, maxPerSubreddit int
+		want                      []string
+	}{
+		{"empty", nil, 2, 1, nil},
+		{"under the cap", []string{"a1", "b1", "a2"}, 3, 2, []string{"a1", "b1", "a2"}},
+		{"over the cap moves to the next page", []string{"a1", "a2", "a3", "b1"}, 2, 1, []string{"a1", "b1", "a2", "a3"}},
+		{"topped up when nothing else is left", []string{"a1", "a2", "b1"}, 3, 1, []string{"a1", "b1", "a2"}},
+		{"order kept within a subreddit", []string{"a1", "a2", "a3", "b1", "b2", "c1"}, 3, 1, []string{"a1", "b1", "c1", "a2", "b2", "a3"}},
+	}
+	for _, tt := range tests {
+		var posts []*Post
+		for _, id := range tt.posts {
+			posts = append(posts, &Post{ID: id, SubredditName: id[:1]})
+		}
+		var got []string
+		for _, post := range capPerSubreddit(posts, tt.pageSize, tt.maxPerSubreddit) {
+			got = append(got, post.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDeletedPostThreadKeepsReplies(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "bob", "still here"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.deletePost("Post 1", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	thread, err := e.getPostThread(&GetPostThread{PostID: "Post 1", Username: "bob"})
+	if err != nil {
+		t.Fatalf("deleted post is unreachable: %v", err)
+	}
+	if thread.Post.Author != "[deleted]" || thread.Content != "[deleted]" {
+		t.Errorf("deleted post shows author %q and content %q", thread.Post.Author, thread.Content)
+	}
+	if len(thread.Comments) != 1 || thread.Comments[0].Content != "still here" {
+		t.Errorf("deleted post lost its replies: %+v", thread.Comments)
+	}
+}
+
+func TestPopularListingPages(t *testing.T) {
+	e := newTestEngine(t)
+	e.registerUser("carol")
+	if err := e.createSubreddit("r/other", "carol", SubredditPublic); err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= 6; i++ {
+		if err := e.createPost(fmt.Sprintf("Post %d", i), "r/test", "alice", "Hello", "More", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.createPost("Post 7", "r/other", "carol", "Hello", "Elsewhere", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	query := &GetGlobalListing{Listing: ListingPopular, Sort: SortNew, Offset: 2, Limit: 4}
+	if _, err := e.getGlobalListing(query); err == nil {
+		t.Fatal("an offset inside a page was accepted")
+	}
+	seen := make(map[string]bool)
+	for query.Offset = 0; query.Offset >= 0; {
+		listing, err := e.getGlobalListing(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, post := range listing.Posts {
+			if seen[post.ID] {
+				t.Errorf("%s shows up on more than one page", post.ID)
+			}
+			seen[post.ID] = true
+		}
+		query.Offset = listing.NextOffset
+	}
+	if len(seen) != 7 {
+		t.Errorf("pages hold %d posts, want 7", len(seen))
+	}
+}
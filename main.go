@@ -17,16 +17,29 @@ func main() {
 		maxSubreddits     = flag.Int("subreddits", 6, "Maximum number of subreddits")
 		simulationActions = flag.Int("actions", 200, "Number of simulation actions")
 		simulationTime    = flag.Int("time", 5, "Simulation time in seconds")
+		actionLimit       = flag.Int("action-limit", 0, "Actions kept in memory per user (0 keeps all)")
+		actionWindow      = flag.Duration("action-window", 0, "Age after which actions leave memory, e.g. 30s (0 keeps all)")
+		actionSpill       = flag.String("action-spill", "", "JSON Lines file receiving actions evicted from memory (dropped when empty)")
+		jsonActions       = flag.Bool("jsonl", false, "Print user actions as JSON Lines")
+		traceOutput       = flag.String("trace", "", "Trace output: \"stdout\" or a file path (disabled when empty)")
 	)
 	flag.Parse()
 
+	shutdownTracing, err := initTracing(*traceOutput)
+	if err != nil {
+		fmt.Printf("Failed to initialise tracing: %v\n", err)
+		return
+	}
+	defer shutdownTracing()
+
 	system := actor.NewActorSystem()
 
-	engineProps := actor.PropsFromProducer(func() actor.Actor { return NewEngine() })
+	retention := ActionRetention{MaxPerUser: *actionLimit, MaxAge: *actionWindow, SpillPath: *actionSpill}
+	engineProps := actor.PropsFromProducer(func() actor.Actor { return NewEngine(retention) })
 	enginePID := system.Root.Spawn(engineProps)
 
 	simulatorProps := actor.PropsFromProducer(func() actor.Actor {
-		return NewSimulator(enginePID, *maxUsers, *maxSubreddits, *simulationActions)
+		return NewSimulator(enginePID, *maxUsers, *maxSubreddits, *simulationActions, *jsonActions)
 	})
 	simulatorPID := system.Root.Spawn(simulatorProps)
 
@@ -34,7 +47,8 @@ func main() {
 	time.Sleep(time.Duration(*simulationTime) * time.Second)
 
 	system.Root.Stop(simulatorPID)
-	system.Root.Stop(enginePID)
+	// wait for the engine so it can flush the action spill file
+	system.Root.StopFuture(enginePID).Wait()
 
 	fmt.Println("PIDs stopped.")
 }
@@ -1,6 +1,10 @@
 package main
 
-import "time"
+import (
+	"time"
+
+	"github.com/asynkron/protoactor-go/actor"
+)
 
 type RegisterUser struct {
 	Username string
@@ -9,6 +13,7 @@ type RegisterUser struct {
 type CreateSubreddit struct {
 	Name    string
 	Creator string
+	Type    SubredditType // public when empty
 }
 
 type JoinSubreddit struct {
@@ -21,12 +26,389 @@ type LeaveSubreddit struct {
 	Username      string
 }
 
+type AddModerator struct {
+	SubredditName string
+	Moderator     string // existing moderator making the change
+	Username      string
+}
+
+type RemoveModerator struct {
+	SubredditName string
+	Moderator     string
+	Username      string
+}
+
+type SetMembershipPolicy struct {
+	SubredditName string
+	Moderator     string
+	Policy        MembershipPolicy
+}
+
+type SetSubredditType struct {
+	SubredditName string
+	Moderator     string
+	Type          SubredditType
+}
+
+// ApproveUser lets a user post in a restricted subreddit, or view and take
+// part in a private one.
+type ApproveUser struct {
+	SubredditName string
+	Moderator     string
+	Username      string
+}
+
+type UnapproveUser struct {
+	SubredditName string
+	Moderator     string
+	Username      string
+}
+
+// RemovePost takes a post down. RuleNumber optionally cites the subreddit
+// rule it broke.
+type RemovePost struct {
+	PostID     string
+	Moderator  string
+	RuleNumber int
+	Reason     string
+}
+
+type RemoveComment struct {
+	PostID     string
+	CommentID  string
+	Moderator  string
+	RuleNumber int
+	Reason     string
+}
+
+// PinPost sticks a post to the top of its subreddit's listing. A subreddit
+// holds at most two pinned posts.
+type PinPost struct {
+	PostID    string
+	Moderator string
+}
+
+type UnpinPost struct {
+	PostID    string
+	Moderator string
+}
+
+// LockPost stops a post from accepting new comments.
+type LockPost struct {
+	PostID    string
+	Moderator string
+}
+
+type UnlockPost struct {
+	PostID    string
+	Moderator string
+}
+
+type UpdateSubredditInfo struct {
+	SubredditName string
+	Moderator     string
+	Description   string
+	Sidebar       string
+}
+
+type AddSubredditRule struct {
+	SubredditName string
+	Moderator     string
+	Rule          SubredditRule
+}
+
+type EditSubredditRule struct {
+	SubredditName string
+	Moderator     string
+	RuleNumber    int
+	Rule          SubredditRule
+}
+
+// RemoveSubredditRule deletes a rule; later rules move up one number.
+type RemoveSubredditRule struct {
+	SubredditName string
+	Moderator     string
+	RuleNumber    int
+}
+
+// GetSubreddit asks for a subreddit's metadata as seen by Username. The
+// engine responds with *SubredditInfo, or a failed *ActionResult when the
+// subreddit is missing or private to the viewer.
+type GetSubreddit struct {
+	SubredditName string
+	Username      string
+}
+
+type SubredditInfo struct {
+	Name        string
+	Creator     string
+	Created     time.Time
+	Type        SubredditType
+	Description string
+	Sidebar     string
+	Rules       []SubredditRule
+	Moderators  []string
+	MemberCount int
+}
+
+// BanUser keeps a user from joining, posting, commenting and voting in a
+// subreddit. A zero Duration bans permanently.
+type BanUser struct {
+	SubredditName string
+	Moderator     string
+	Username      string
+	Reason        string
+	Duration      time.Duration
+}
+
+type UnbanUser struct {
+	SubredditName string
+	Moderator     string
+	Username      string
+}
+
+// MuteUser stops a user from posting and commenting in a subreddit while
+// leaving membership and voting alone. A zero Duration mutes permanently.
+type MuteUser struct {
+	SubredditName string
+	Moderator     string
+	Username      string
+	Reason        string
+	Duration      time.Duration
+}
+
+type UnmuteUser struct {
+	SubredditName string
+	Moderator     string
+	Username      string
+}
+
+type AddAutoModRule struct {
+	SubredditName string
+	Moderator     string
+	Rule          AutoModRule
+}
+
+type RemoveAutoModRule struct {
+	SubredditName string
+	Moderator     string
+	RuleName      string
+}
+
+// GetModLog asks for a subreddit's moderation log, oldest first, optionally
+// restricted to some actions. Only moderators may read it: the engine
+// responds with *ModLog, or with a failed *ActionResult otherwise.
+type GetModLog struct {
+	SubredditName string
+	Moderator     string
+	Actions       []ActionKind
+	Offset        int
+	Limit         int
+}
+
+type ModLog struct {
+	SubredditName string
+	Entries       []ModLogEntry
+	Total         int
+	NextOffset    int // -1 once the last page has been returned
+}
+
 type CreatePost struct {
 	PostID        string
 	SubredditName string
 	Author        string
 	Title         string
 	Content       string
+	Flair         string // one of the subreddit's post flairs, or empty
+}
+
+type AddPostFlair struct {
+	SubredditName string
+	Moderator     string
+	Flair         string
+}
+
+type RemovePostFlair struct {
+	SubredditName string
+	Moderator     string
+	Flair         string
+}
+
+// SetUserFlair sets the flair shown next to Username in a subreddit. Users
+// may set their own; moderators may set anyone's. An empty Flair clears it.
+type SetUserFlair struct {
+	SubredditName string
+	SetBy         string
+	Username      string
+	Flair         string
+}
+
+type ListingSort string
+
+const (
+	SortNew ListingSort = "new"
+	SortHot ListingSort = "hot"
+	SortTop ListingSort = "top" // highest net votes
+)
+
+// GetSubredditListing asks for a subreddit's visible posts, pinned posts
+// first and then in Sort order (newest first by default), optionally only
+// those with the given flair. The engine responds with *PostListing, or a
+// failed *ActionResult if Username may not view it.
+type GetSubredditListing struct {
+	SubredditName string
+	Username      string
+	Flair         string
+	Sort          ListingSort
+	Offset        int
+	Limit         int
+}
+
+type GlobalListing string
+
+const (
+	ListingAll     GlobalListing = "all"
+	ListingPopular GlobalListing = "popular" // no subreddit fills more than a quarter of a page
+)
+
+// GetGlobalListing asks for posts across every subreddit that is not private,
+// like r/all and r/popular. The engine responds with *PostListing.
+type GetGlobalListing struct {
+	Listing  GlobalListing
+	Username string // posts by users they blocked are left out
+	Flair    string
+	Sort     ListingSort
+	Offset   int // popular listings only accept multiples of Limit
+	Limit    int
+}
+
+// GetPostThread asks for a post and its comment tree as Username sees it.
+// Requesters get a *PostThread back.
+type GetPostThread struct {
+	PostID   string
+	Username string
+}
+
+// CommentView is a comment as shown in a thread. Removed and deleted comments,
+// and those by users the viewer blocked, keep their place in the tree with
+// their author cleared and a placeholder as content.
+type CommentView struct {
+	ID      string
+	Author  string
+	Content string
+	Created time.Time
+	Edited  time.Time
+	Replies []CommentView
+}
+
+type PostThread struct {
+	Post     PostSummary
+	Content  string
+	Comments []CommentView
+}
+
+// PostSummary is a read-only copy of a post as shown in listings and feeds.
+type PostSummary struct {
+	ID            string
+	SubredditName string
+	Author        string
+	AuthorFlair   string
+	Title         string
+	Flair         string
+	Upvotes       int
+	Downvotes     int
+	CommentCount  int
+	Pinned        bool
+	Locked        bool
+	Created       time.Time
+}
+
+type PostListing struct {
+	Posts      []PostSummary
+	Total      int
+	NextOffset int // -1 once the last page has been returned
+}
+
+type DirectorySort string
+
+const (
+	DirectoryByMembers  DirectorySort = "members"
+	DirectoryByActivity DirectorySort = "activity"
+	DirectoryByNewest   DirectorySort = "new"
+)
+
+// SearchSubreddits browses the subreddit directory. Query matches name
+// prefixes, tolerating small typos, and descriptions; an empty query lists
+// every subreddit the user can see. Requesters get a *SubredditDirectory back.
+type SearchSubreddits struct {
+	Query    string
+	Username string
+	Sort     DirectorySort // defaults to DirectoryByMembers
+	Offset   int
+	Limit    int
+}
+
+type SubredditSummary struct {
+	Name         string
+	Description  string
+	Type         SubredditType
+	MemberCount  int
+	PostCount    int
+	Created      time.Time
+	LastActivity time.Time
+}
+
+type SubredditDirectory struct {
+	Subreddits []SubredditSummary
+	Total      int
+	NextOffset int // -1 once the last page has been returned
+}
+
+// RecommendSubreddits asks for subreddits the user may want to join, best
+// first. Requesters get a *SubredditRecommendations back.
+type RecommendSubreddits struct {
+	Username string
+	Limit    int
+}
+
+type SubredditRecommendation struct {
+	Name        string
+	Score       float64
+	MemberCount int
+}
+
+type SubredditRecommendations struct {
+	Username   string
+	Subreddits []SubredditRecommendation
+}
+
+// Search runs a full-text query over posts and comments. Words must all
+// appear; double-quoted phrases must appear in order. Requesters get a
+// *SearchResults back, best matches first.
+type Search struct {
+	Query         string
+	Username      string
+	SubredditName string // optional filter
+	Author        string // optional filter
+	Offset        int
+	Limit         int
+}
+
+// SearchResult is a matching post, or a comment when CommentID is set.
+type SearchResult struct {
+	PostID        string
+	CommentID     string
+	SubredditName string
+	Author        string
+	Title         string // of the post, also for comments
+	Content       string
+	Score         float64
+}
+
+type SearchResults struct {
+	Results    []SearchResult
+	Total      int
+	NextOffset int // -1 once the last page has been returned
 }
 
 // type CreateComment struct {
@@ -43,25 +425,304 @@ type CreateComment struct {
 	Content   string
 }
 
+// EditPost replaces the content of a post. Only its author may edit it; the
+// previous content is kept in the post's revision history.
+type EditPost struct {
+	PostID  string
+	Author  string
+	Content string
+}
+
+// DeletePost takes a post down at its author's request.
+type DeletePost struct {
+	PostID string
+	Author string
+}
+
+type EditComment struct {
+	PostID    string
+	CommentID string
+	Author    string
+	Content   string
+}
+
+// DeleteComment blanks a comment to a "[deleted]" placeholder; replies to it
+// stay in place.
+type DeleteComment struct {
+	PostID    string
+	CommentID string
+	Author    string
+}
+
+// GetEditHistory asks for every version of a post, or of one of its comments
+// when CommentID is set. Requesters get an *EditHistory back.
+type GetEditHistory struct {
+	PostID    string
+	CommentID string
+	Username  string
+}
+
+type EditHistory struct {
+	PostID    string
+	CommentID string
+	Revisions []Revision // oldest first, ending with the current content
+}
+
 type Vote struct {
 	PostID   string
 	UserID   string
 	IsUpvote bool
 }
 
+// SendDirectMessage starts a conversation, or continues the conversation of
+// the message in ReplyTo, which must be between the same two users.
 type SendDirectMessage struct {
-	From    string
-	To      string
-	Content string
+	MessageID string // assigned by the engine when empty
+	ReplyTo   string
+	From      string
+	To        string
+	Content   string
+}
+
+// GetInbox lists the user's direct messages, replies and mentions, newest
+// first, optionally only one kind or only unread items. Requesters get an
+// *Inbox back.
+type GetInbox struct {
+	Username   string
+	Kind       InboxKind
+	UnreadOnly bool
+	Offset     int
+	Limit      int
 }
 
+type Inbox struct {
+	Items       []InboxItem
+	Total       int
+	TotalUnread int               // across the whole inbox, ignoring filters
+	Unread      map[InboxKind]int // unread items by kind
+	NextOffset  int               // -1 once the last page has been returned
+}
+
+// GetNotifications lists the user's reply and mention notifications, newest
+// first, leaving out direct messages. Requesters get a *Notifications back.
+type GetNotifications struct {
+	Username   string
+	UnreadOnly bool
+	Offset     int
+	Limit      int
+}
+
+type Notifications struct {
+	Items       []InboxItem
+	Total       int
+	Unread      int
+	Preferences NotificationPreferences
+	NextOffset  int // -1 once the last page has been returned
+}
+
+// SetNotificationPreferences turns kinds of notification on or off for the
+// user. Notifications already delivered are kept.
+type SetNotificationPreferences struct {
+	Username    string
+	Preferences NotificationPreferences
+}
+
+// MarkInboxRead marks the given inbox items as read. Nothing is marked if
+// any of the IDs is unknown.
+type MarkInboxRead struct {
+	Username string
+	ItemIDs  []string
+}
+
+type MarkAllInboxRead struct {
+	Username string
+}
+
+// BlockUser stops Blocked from messaging Username or replying to their posts
+// and comments, and hides Blocked's posts and comments from Username.
+type BlockUser struct {
+	Username string
+	Blocked  string
+}
+
+type UnblockUser struct {
+	Username string
+	Blocked  string
+}
+
+// GetBlockedUsers asks for the users Username has blocked. Requesters get a
+// *BlockedUsers back.
+type GetBlockedUsers struct {
+	Username string
+}
+
+type BlockedUsers struct {
+	Username string
+	Blocked  []string
+}
+
+// Connect marks the user online. Inbox items that arrived while they were
+// offline are sent to Client as one backlog *Delivery, and new ones follow
+// as they arrive until Disconnect.
+type Connect struct {
+	Username string
+	Client   *actor.PID
+}
+
+type Disconnect struct {
+	Username string
+}
+
+// Delivery pushes inbox items to a connected client.
+type Delivery struct {
+	Username string
+	Items    []InboxItem
+	Backlog  bool // queued while the user was offline
+}
+
+// GetConversations lists the user's conversations, most recently active
+// first. Requesters get a *ConversationList back.
+type GetConversations struct {
+	Username string
+	Offset   int
+	Limit    int
+}
+
+type ConversationSummary struct {
+	ID           string
+	With         string // the other participant
+	LastMessage  DirectMessage
+	MessageCount int
+	Unread       int // messages to the user they have not read yet
+}
+
+type ConversationList struct {
+	Conversations []ConversationSummary
+	Total         int
+	NextOffset    int // -1 once the last page has been returned
+}
+
+// GetConversation fetches a page of a conversation's messages, oldest first,
+// and marks the messages on it that were sent to Username as read.
+// Requesters get a *ConversationMessages back.
+type GetConversation struct {
+	ConversationID string
+	Username       string
+	Offset         int
+	Limit          int
+}
+
+type ConversationMessages struct {
+	ID         string
+	Messages   []DirectMessage
+	Total      int
+	NextOffset int // -1 once the last page has been returned
+}
+
+// Report flags a post, comment or direct message. PostID identifies posts and,
+// together with CommentID, comments; MessageID identifies direct messages.
+// Message reports go to the site-wide queue read with GetMessageReports, as
+// no subreddit's moderators cover direct messages.
+type Report struct {
+	Reporter   string
+	TargetType ReportTargetType
+	PostID     string
+	CommentID  string
+	MessageID  string
+	RuleNumber int // subreddit rule broken; posts and comments only
+	Reason     string
+}
+
+// GetModQueue asks for a subreddit's reported items, most reported first.
+// The engine responds with *ModQueue, or a failed *ActionResult for
+// non-moderators.
+type GetModQueue struct {
+	SubredditName string
+	Moderator     string
+	Offset        int
+	Limit         int
+}
+
+type ModQueue struct {
+	SubredditName string
+	Items         []ReportedItem
+	Total         int
+	NextOffset    int // -1 once the last page has been returned
+}
+
+// GetMessageReports asks for the site-wide queue of reported direct messages,
+// most reported first. The engine has no site admin accounts, so the queue is
+// not restricted. Requesters get a *MessageReports back.
+type GetMessageReports struct {
+	Offset int
+	Limit  int
+}
+
+type MessageReports struct {
+	Items      []ReportedItem
+	Total      int
+	NextOffset int // -1 once the last page has been returned
+}
+
+// ApproveReported clears the reports on a post or comment, keeping it up.
+// Reported items are taken down with RemovePost or RemoveComment.
+type ApproveReported struct {
+	Moderator string
+	PostID    string
+	CommentID string // empty to approve the post itself
+}
+
+// ActionResult is sent back to requesters of operations that can be rejected.
+type ActionResult struct {
+	Success bool
+	Reason  string
+}
+
+// GetFeed lists posts from the user's subscriptions, optionally only those
+// with the given flair. Requesters get a *PostListing back.
 type GetFeed struct {
 	Username string
+	Flair    string
+	Offset   int
+	Limit    int
+}
+
+// GetHomeFeed is the personalized "best" feed: hot posts from the user's
+// subscriptions with about RecommendedShare of each page (0 to 1) taken from
+// recommended subreddits. Posts the user hid or voted on are left out.
+// Requesters get a *PostListing back.
+type GetHomeFeed struct {
+	Username         string
+	RecommendedShare float64
+	Offset           int
+	Limit            int
+}
+
+// HidePost keeps a post out of the user's home feed.
+type HidePost struct {
+	PostID   string
+	Username string
+}
+
+type UnhidePost struct {
+	PostID   string
+	Username string
 }
 
 type UserAction struct {
-	Action    string
-	Timestamp time.Time
+	Kind       ActionKind `json:"kind"`
+	Actor      string     `json:"actor"`
+	Subreddit  string     `json:"subreddit,omitempty"`
+	PostID     string     `json:"post_id,omitempty"`
+	CommentID  string     `json:"comment_id,omitempty"`
+	ParentID   string     `json:"parent_id,omitempty"`
+	MessageID  string     `json:"message_id,omitempty"`
+	TargetUser string     `json:"target_user,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Content    string     `json:"content,omitempty"`
+	VoteType   string     `json:"vote_type,omitempty"`
+	PostIDs    []string   `json:"post_ids,omitempty"`
+	Timestamp  time.Time  `json:"timestamp"`
 }
 
 type UserActions struct {
@@ -69,8 +730,28 @@ type UserActions struct {
 	Actions  []UserAction
 }
 
+// GetUserActivity asks for one user's logged actions, oldest first. Empty
+// Kinds and zero Since/Until match everything; a zero Limit returns all
+// remaining entries from Offset. The engine responds with *UserActivity.
+type GetUserActivity struct {
+	Username string
+	Kinds    []ActionKind
+	Since    time.Time
+	Until    time.Time
+	Offset   int
+	Limit    int
+}
+
+type UserActivity struct {
+	UserActions
+	Total      int
+	NextOffset int // -1 once the last page has been returned
+}
+
 //User wise Actions
-type PrintUserActions struct{}
+type PrintUserActions struct {
+	JSONLines bool
+}
 
 // simulation stats
 type GetSimulationStats struct{}
@@ -1,17 +1,114 @@
 package main
 
+import (
+	"regexp"
+	"time"
+)
+
 type User struct {
 	Username             string
+	Created              time.Time
 	Karma                int
 	SubscribedSubreddits []string
 	SentMessages         []*DirectMessage
 	ReceivedMessages     []*DirectMessage
+	BlockedUsers         []string
+	Inbox                []*InboxItem    // in delivery order
+	PendingDelivery      []*InboxItem    // inbox items that arrived while offline
+	Notifications        NotificationPreferences
+	HiddenPosts          map[string]bool // post IDs left out of the home feed
+	VotedPosts           map[string]bool // post ID to whether the vote was up
+}
+
+type SubredditType string
+
+const (
+	// anyone can view, join and post
+	SubredditPublic SubredditType = "public"
+	// anyone can view and join, only approved users can post
+	SubredditRestricted SubredditType = "restricted"
+	// only approved users can view, join, post, comment and vote
+	SubredditPrivate SubredditType = "private"
+)
+
+type SubredditRule struct {
+	Title       string
+	Description string
+}
+
+// MembershipPolicy controls whether only members may post and comment.
+// Moderators are never held to it.
+type MembershipPolicy struct {
+	PostRequiresMembership    bool
+	CommentRequiresMembership bool
 }
 
 type Subreddit struct {
-	Name    string
-	Creator string
-	Members []string
+	Name          string
+	Creator       string
+	Created       time.Time
+	LastActivity  time.Time // latest post or comment
+	Description   string
+	Sidebar       string
+	Rules         []SubredditRule // rule N is Rules[N-1]
+	Type          SubredditType
+	Members       []string
+	Moderators    []string // the creator is always first
+	ApprovedUsers []string
+	Policy        MembershipPolicy
+	PostFlairs    []string          // flairs authors may attach to posts
+	UserFlairs    map[string]string // username to flair shown next to their name
+	PinnedPosts   []string          // at most maxPinnedPosts, shown first in listings
+	Banned        map[string]*Restriction
+	Muted         map[string]*Restriction
+	ModLog        []*ModLogEntry
+	// AutoModRules are checked in order on every new post and comment; the
+	// first matching rule is applied.
+	AutoModRules []*AutoModRule
+}
+
+type AutoModAction string
+
+const (
+	AutoModRemove AutoModAction = "remove"
+	AutoModHold   AutoModAction = "hold"
+	AutoModTag    AutoModAction = "tag"
+)
+
+// AutoModRule fires when every condition it sets holds: the title or content
+// contains one of Keywords, matches Pattern, or links to one of
+// BlockedDomains, and the author has less than MinKarma karma or an account
+// younger than MinAccountAge. Unset conditions are ignored.
+type AutoModRule struct {
+	Name           string
+	Keywords       []string
+	Pattern        string
+	BlockedDomains []string
+	MinKarma       int
+	MinAccountAge  time.Duration
+	Action         AutoModAction
+	Tag            string // applied with AutoModTag
+
+	pattern *regexp.Regexp
+}
+
+// ModLogEntry records one moderator action in a subreddit. Target is the
+// user, post or comment acted upon.
+type ModLogEntry struct {
+	Moderator string
+	Action    ActionKind
+	Target    string
+	Reason    string
+	Timestamp time.Time
+}
+
+// Restriction is a ban or mute placed on a user by a subreddit moderator.
+type Restriction struct {
+	Username  string
+	Moderator string
+	Reason    string
+	Created   time.Time
+	Expires   time.Time // zero for a permanent restriction
 }
 
 type Post struct {
@@ -20,21 +117,147 @@ type Post struct {
 	Author        string
 	Title         string
 	Content       string
+	Flair         string
+	Created       time.Time
 	Upvotes       int
 	Downvotes     int
 	Comments      []*Comment
+	Edited        time.Time
+	Revisions     []Revision // earlier versions of Content, oldest first
+	RemovedBy     string     // moderator who removed the post, empty if visible
+	Deleted       bool       // deleted by its author
+	Held          bool       // waiting for moderator approval
+	Locked        bool       // no new comments accepted
+	Tags          []string
 }
 
 type Comment struct {
-	ID       string
-	ParentID string
-	Author   string
-	Content  string
-	Children []*Comment
+	ID        string
+	ParentID  string
+	Author    string
+	Content   string
+	Children  []*Comment
+	Created   time.Time
+	Edited    time.Time
+	Revisions []Revision
+	RemovedBy string
+	Deleted   bool // kept in the tree as a placeholder so replies stay reachable
+	Held      bool
+	Tags      []string
 }
 
-type DirectMessage struct {
-	From    string
-	To      string
+// Revision is a superseded version of a post's or comment's content together
+// with the time it was written.
+type Revision struct {
 	Content string
+	Written time.Time
+}
+
+type DirectMessage struct {
+	ID             string
+	ConversationID string // ID of the message that started the conversation
+	ReplyTo        string // empty for the first message of a conversation
+	From           string
+	To             string
+	Content        string
+	Sent           time.Time
+	Read           bool // seen by the recipient
+}
+
+// Conversation is a thread of direct messages between two users, in both
+// directions, oldest first.
+type Conversation struct {
+	ID           string
+	Participants []string
+	Messages     []*DirectMessage
+}
+
+type InboxKind string
+
+const (
+	InboxMessage      InboxKind = "message"
+	InboxPostReply    InboxKind = "post_reply"    // comment on the user's post
+	InboxCommentReply InboxKind = "comment_reply" // reply to the user's comment
+	InboxMention      InboxKind = "mention"
+)
+
+// NotificationPreferences chooses which kinds of notification reach a user's
+// inbox. Direct messages always do.
+type NotificationPreferences struct {
+	PostReplies    bool
+	CommentReplies bool
+	Mentions       bool
+}
+
+// allows reports whether notifications of kind are turned on.
+func (p NotificationPreferences) allows(kind InboxKind) bool {
+	switch kind {
+	case InboxPostReply:
+		return p.PostReplies
+	case InboxCommentReply:
+		return p.CommentReplies
+	case InboxMention:
+		return p.Mentions
+	}
+	return true
+}
+
+// InboxItem is a direct message, reply or mention delivered to a user. IDs
+// are "message:<message id>", "reply:<post id>/<comment id>" and
+// "mention:<post id>" or "mention:<post id>/<comment id>".
+type InboxItem struct {
+	ID        string
+	Kind      InboxKind
+	From      string
+	PostID    string
+	CommentID string
+	MessageID string
+	Content   string
+	Created   time.Time
+	Read      bool
+	// message items share read state with the message itself, which opening
+	// its conversation also sets
+	message *DirectMessage
+}
+
+func (item *InboxItem) isRead() bool {
+	if item.message != nil {
+		return item.message.Read
+	}
+	return item.Read
+}
+
+func (item *InboxItem) markRead() {
+	item.Read = true
+	if item.message != nil {
+		item.message.Read = true
+	}
+}
+
+type ReportTargetType string
+
+const (
+	ReportPost    ReportTargetType = "post"
+	ReportComment ReportTargetType = "comment"
+	ReportMessage ReportTargetType = "message"
+)
+
+// ReportedItem aggregates every report filed against one post, comment or
+// direct message. Message reports have no subreddit and are queued site-wide.
+type ReportedItem struct {
+	TargetType    ReportTargetType
+	SubredditName string
+	PostID        string
+	CommentID     string
+	MessageID     string
+	Author        string
+	Reports       []ReportEntry
+}
+
+type ReportEntry struct {
+	Reporter   string
+	RuleNumber int    // subreddit rule broken, 0 if none given or since removed
+	RuleTitle  string // title of the cited rule when it was reported
+	Reason     string
+	Timestamp  time.Time
 }
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asynkron/protoactor-go/actor"
+)
+
+// respond reports the outcome of a rejectable operation to the requester.
+// Fire-and-forget senders have no reply address and get nothing back.
+func (e *Engine) respond(context actor.Context, err error) {
+	if err != nil {
+		e.reply(context, &ActionResult{Success: false, Reason: err.Error()})
+		return
+	}
+	e.reply(context, &ActionResult{Success: true})
+}
+
+// reply sends response to the requester, if the message came with one.
+func (e *Engine) reply(context actor.Context, response interface{}) {
+	if context.Sender() != nil {
+		context.Respond(response)
+	}
+}
+
+func (e *Engine) isModerator(subreddit *Subreddit, username string) bool {
+	return contains(subreddit.Moderators, username)
+}
+
+// moderatedSubreddit looks up a subreddit and checks that moderator may act on it.
+func (e *Engine) moderatedSubreddit(subredditName, moderator string) (*Subreddit, error) {
+	subreddit, exists := e.subreddits[subredditName]
+	if !exists {
+		return nil, fmt.Errorf("subreddit %s does not exist", subredditName)
+	}
+	if !e.isModerator(subreddit, moderator) {
+		return nil, fmt.Errorf("%s is not a moderator of %s", moderator, subredditName)
+	}
+	return subreddit, nil
+}
+
+func (e *Engine) addModerator(subredditName, moderator, username string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if _, exists := e.users[username]; !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if e.isModerator(subreddit, username) {
+		return fmt.Errorf("%s is already a moderator of %s", username, subredditName)
+	}
+	subreddit.Moderators = append(subreddit.Moderators, username)
+	e.logModAction(subreddit, UserAction{Kind: ActionAddModerator, Actor: moderator, Subreddit: subredditName, TargetUser: username})
+	return nil
+}
+
+func (e *Engine) removeModerator(subredditName, moderator, username string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if !e.isModerator(subreddit, username) {
+		return fmt.Errorf("%s is not a moderator of %s", username, subredditName)
+	}
+	if username == subreddit.Creator {
+		return fmt.Errorf("the creator of %s cannot be removed as moderator", subredditName)
+	}
+	subreddit.Moderators = remove(subreddit.Moderators, username)
+	e.logModAction(subreddit, UserAction{Kind: ActionRemoveModerator, Actor: moderator, Subreddit: subredditName, TargetUser: username})
+	return nil
+}
+
+func (e *Engine) removePost(postID, moderator string, ruleNumber int, reason string) error {
+	post, exists := e.posts[postID]
+	if !exists {
+		return fmt.Errorf("post %s does not exist", postID)
+	}
+	subreddit, err := e.moderatedSubreddit(post.SubredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if post.RemovedBy != "" {
+		return fmt.Errorf("post %s is already removed", postID)
+	}
+	if reason, err = citeRule(subreddit, ruleNumber, reason); err != nil {
+		return err
+	}
+	post.RemovedBy = moderator
+	subreddit.PinnedPosts = remove(subreddit.PinnedPosts, postID)
+	e.clearReports(ReportPost, postID, "")
+	e.logModAction(subreddit, UserAction{Kind: ActionRemovePost, Actor: moderator, Subreddit: subreddit.Name, PostID: postID, TargetUser: post.Author, Content: reason})
+	return nil
+}
+
+func (e *Engine) removeComment(postID, commentID, moderator string, ruleNumber int, reason string) error {
+	post, exists := e.posts[postID]
+	if !exists {
+		return fmt.Errorf("post %s does not exist", postID)
+	}
+	subreddit, err := e.moderatedSubreddit(post.SubredditName, moderator)
+	if err != nil {
+		return err
+	}
+	comment := findComment(post.Comments, commentID)
+	if comment == nil {
+		return fmt.Errorf("comment %s does not exist on post %s", commentID, postID)
+	}
+	if comment.RemovedBy != "" {
+		return fmt.Errorf("comment %s is already removed", commentID)
+	}
+	if reason, err = citeRule(subreddit, ruleNumber, reason); err != nil {
+		return err
+	}
+	// the comment stays in the tree so its replies remain reachable
+	comment.RemovedBy = moderator
+	e.clearReports(ReportComment, postID, commentID)
+	e.logModAction(subreddit, UserAction{Kind: ActionRemoveComment, Actor: moderator, Subreddit: subreddit.Name, PostID: postID, CommentID: commentID, TargetUser: comment.Author, Content: reason})
+	return nil
+}
+
+// maxPinnedPosts is how many posts a subreddit can pin to the top of its listing.
+const maxPinnedPosts = 2
+
+// moderatedPost looks up a post whose subreddit is moderated by moderator.
+func (e *Engine) moderatedPost(postID, moderator string) (*Post, *Subreddit, error) {
+	post, exists := e.posts[postID]
+	if !exists || post.RemovedBy != "" || post.Deleted {
+		return nil, nil, fmt.Errorf("post %s does not exist", postID)
+	}
+	subreddit, err := e.moderatedSubreddit(post.SubredditName, moderator)
+	if err != nil {
+		return nil, nil, err
+	}
+	return post, subreddit, nil
+}
+
+func (e *Engine) pinPost(postID, moderator string) error {
+	post, subreddit, err := e.moderatedPost(postID, moderator)
+	if err != nil {
+		return err
+	}
+	if contains(subreddit.PinnedPosts, postID) {
+		return fmt.Errorf("post %s is already pinned", postID)
+	}
+	if len(subreddit.PinnedPosts) >= maxPinnedPosts {
+		return fmt.Errorf("%s already has %d pinned posts", subreddit.Name, maxPinnedPosts)
+	}
+	subreddit.PinnedPosts = append(subreddit.PinnedPosts, postID)
+	e.logModAction(subreddit, UserAction{Kind: ActionPinPost, Actor: moderator, Subreddit: subreddit.Name, PostID: postID, TargetUser: post.Author})
+	return nil
+}
+
+func (e *Engine) unpinPost(postID, moderator string) error {
+	post, subreddit, err := e.moderatedPost(postID, moderator)
+	if err != nil {
+		return err
+	}
+	if !contains(subreddit.PinnedPosts, postID) {
+		return fmt.Errorf("post %s is not pinned", postID)
+	}
+	subreddit.PinnedPosts = remove(subreddit.PinnedPosts, postID)
+	e.logModAction(subreddit, UserAction{Kind: ActionUnpinPost, Actor: moderator, Subreddit: subreddit.Name, PostID: postID, TargetUser: post.Author})
+	return nil
+}
+
+func (e *Engine) lockPost(postID, moderator string) error {
+	post, subreddit, err := e.moderatedPost(postID, moderator)
+	if err != nil {
+		return err
+	}
+	if post.Locked {
+		return fmt.Errorf("post %s is already locked", postID)
+	}
+	post.Locked = true
+	e.logModAction(subreddit, UserAction{Kind: ActionLockPost, Actor: moderator, Subreddit: subreddit.Name, PostID: postID, TargetUser: post.Author})
+	return nil
+}
+
+func (e *Engine) unlockPost(postID, moderator string) error {
+	post, subreddit, err := e.moderatedPost(postID, moderator)
+	if err != nil {
+		return err
+	}
+	if !post.Locked {
+		return fmt.Errorf("post %s is not locked", postID)
+	}
+	post.Locked = false
+	e.logModAction(subreddit, UserAction{Kind: ActionUnlockPost, Actor: moderator, Subreddit: subreddit.Name, PostID: postID, TargetUser: post.Author})
+	return nil
+}
+
+// logModAction records a moderator's action in the subreddit's moderation log
+// as well as in the moderator's own action history.
+func (e *Engine) logModAction(subreddit *Subreddit, action UserAction) {
+	e.logUserAction(action)
+	// subreddit-wide changes such as rule and flair edits target the
+	// subreddit itself
+	target := subreddit.Name
+	switch {
+	case action.CommentID != "":
+		target = action.CommentID
+	case action.PostID != "":
+		target = action.PostID
+	case action.TargetUser != "":
+		target = action.TargetUser
+	}
+	subreddit.ModLog = append(subreddit.ModLog, &ModLogEntry{
+		Moderator: action.Actor,
+		Action:    action.Kind,
+		Target:    target,
+		Reason:    action.Content,
+		Timestamp: time.Now(),
+	})
+}
+
+func (e *Engine) getModLog(query *GetModLog) (*ModLog, error) {
+	subreddit, err := e.moderatedSubreddit(query.SubredditName, query.Moderator)
+	if err != nil {
+		return nil, err
+	}
+	// entries are copied so the log cannot be changed through the response
+	var matched []ModLogEntry
+	for _, entry := range subreddit.ModLog {
+		if len(query.Actions) == 0 || containsKind(query.Actions, entry.Action) {
+			matched = append(matched, *entry)
+		}
+	}
+	start, end := paginate(len(matched), query.Offset, query.Limit)
+	modLog := &ModLog{SubredditName: subreddit.Name, Entries: matched[start:end], Total: len(matched), NextOffset: -1}
+	if end < len(matched) {
+		modLog.NextOffset = end
+	}
+	return modLog, nil
+}
+
+func findComment(comments []*Comment, commentID string) *Comment {
+	for _, comment := range comments {
+		if comment.ID == commentID {
+			return comment
+		}
+		if found := findComment(comment.Children, commentID); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// activeRestriction returns the user's entry in restrictions, lifting it first
+// if it has expired.
+func (e *Engine) activeRestriction(restrictions map[string]*Restriction, username string) *Restriction {
+	restriction, exists := restrictions[username]
+	if !exists {
+		return nil
+	}
+	if !restriction.Expires.IsZero() && !time.Now().Before(restriction.Expires) {
+		delete(restrictions, username)
+		return nil
+	}
+	return restriction
+}
+
+// checkCanContribute rejects posts and comments from users who are banned or
+// muted in the subreddit.
+func (e *Engine) checkCanContribute(subreddit *Subreddit, username string) error {
+	if e.activeRestriction(subreddit.Banned, username) != nil {
+		return fmt.Errorf("%s is banned from %s", username, subreddit.Name)
+	}
+	if e.activeRestriction(subreddit.Muted, username) != nil {
+		return fmt.Errorf("%s is muted in %s", username, subreddit.Name)
+	}
+	return nil
+}
+
+// restrict validates a ban or mute request and records it in restrictions.
+func (e *Engine) restrict(restrictions map[string]*Restriction, subreddit *Subreddit, moderator, username, reason string, duration time.Duration) error {
+	if _, exists := e.users[username]; !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if e.isModerator(subreddit, username) {
+		return fmt.Errorf("%s is a moderator of %s", username, subreddit.Name)
+	}
+	restriction := &Restriction{Username: username, Moderator: moderator, Reason: reason, Created: time.Now()}
+	if duration > 0 {
+		restriction.Expires = restriction.Created.Add(duration)
+	}
+	restrictions[username] = restriction
+	return nil
+}
+
+func (e *Engine) banUser(subredditName, moderator, username, reason string, duration time.Duration) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if err := e.restrict(subreddit.Banned, subreddit, moderator, username, reason, duration); err != nil {
+		return err
+	}
+	// a banned user no longer receives the subreddit in their feed
+	if contains(subreddit.Members, username) {
+		subreddit.Members = remove(subreddit.Members, username)
+		e.users[username].SubscribedSubreddits = remove(e.users[username].SubscribedSubreddits, subredditName)
+	}
+	e.logModAction(subreddit, UserAction{Kind: ActionBanUser, Actor: moderator, Subreddit: subredditName, TargetUser: username, Content: reason})
+	return nil
+}
+
+func (e *Engine) unbanUser(subredditName, moderator, username string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if e.activeRestriction(subreddit.Banned, username) == nil {
+		return fmt.Errorf("%s is not banned from %s", username, subredditName)
+	}
+	delete(subreddit.Banned, username)
+	e.logModAction(subreddit, UserAction{Kind: ActionUnbanUser, Actor: moderator, Subreddit: subredditName, TargetUser: username})
+	return nil
+}
+
+func (e *Engine) muteUser(subredditName, moderator, username, reason string, duration time.Duration) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if err := e.restrict(subreddit.Muted, subreddit, moderator, username, reason, duration); err != nil {
+		return err
+	}
+	e.logModAction(subreddit, UserAction{Kind: ActionMuteUser, Actor: moderator, Subreddit: subredditName, TargetUser: username, Content: reason})
+	return nil
+}
+
+func (e *Engine) unmuteUser(subredditName, moderator, username string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if e.activeRestriction(subreddit.Muted, username) == nil {
+		return fmt.Errorf("%s is not muted in %s", username, subredditName)
+	}
+	delete(subreddit.Muted, username)
+	e.logModAction(subreddit, UserAction{Kind: ActionUnmuteUser, Actor: moderator, Subreddit: subredditName, TargetUser: username})
+	return nil
+}
+
+func validSubredditType(subredditType SubredditType) bool {
+	switch subredditType {
+	case SubredditPublic, SubredditRestricted, SubredditPrivate:
+		return true
+	}
+	return false
+}
+
+func (e *Engine) isApproved(subreddit *Subreddit, username string) bool {
+	return e.isModerator(subreddit, username) || contains(subreddit.ApprovedUsers, username)
+}
+
+// checkCanView rejects users who may not see a private subreddit, which also
+// keeps them from joining, commenting and voting there.
+func (e *Engine) checkCanView(subreddit *Subreddit, username string) error {
+	if subreddit.Type == SubredditPrivate && !e.isApproved(subreddit, username) {
+		return fmt.Errorf("%s is private and %s is not an approved user", subreddit.Name, username)
+	}
+	return nil
+}
+
+// checkCanSubmit rejects posts from users not approved in restricted and
+// private subreddits.
+func (e *Engine) checkCanSubmit(subreddit *Subreddit, username string) error {
+	if subreddit.Type != SubredditPublic && !e.isApproved(subreddit, username) {
+		return fmt.Errorf("%s is %s and %s is not an approved user", subreddit.Name, subreddit.Type, username)
+	}
+	return nil
+}
+
+func (e *Engine) setSubredditType(subredditName, moderator string, subredditType SubredditType) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if !validSubredditType(subredditType) {
+		return fmt.Errorf("unknown subreddit type %q", subredditType)
+	}
+	subreddit.Type = subredditType
+	e.logModAction(subreddit, UserAction{Kind: ActionSetSubredditType, Actor: moderator, Subreddit: subredditName, Content: string(subredditType)})
+	return nil
+}
+
+func (e *Engine) approveUser(subredditName, moderator, username string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if _, exists := e.users[username]; !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if contains(subreddit.ApprovedUsers, username) {
+		return fmt.Errorf("%s is already approved in %s", username, subredditName)
+	}
+	subreddit.ApprovedUsers = append(subreddit.ApprovedUsers, username)
+	e.logModAction(subreddit, UserAction{Kind: ActionApproveUser, Actor: moderator, Subreddit: subredditName, TargetUser: username})
+	return nil
+}
+
+func (e *Engine) unapproveUser(subredditName, moderator, username string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if !contains(subreddit.ApprovedUsers, username) {
+		return fmt.Errorf("%s is not approved in %s", username, subredditName)
+	}
+	subreddit.ApprovedUsers = remove(subreddit.ApprovedUsers, username)
+	e.logModAction(subreddit, UserAction{Kind: ActionUnapproveUser, Actor: moderator, Subreddit: subredditName, TargetUser: username})
+	return nil
+}
+
+// checkMembership rejects users who have not joined the subreddit, for
+// subreddits whose policy limits posting or commenting to members.
+func (e *Engine) checkMembership(subreddit *Subreddit, username string) error {
+	if !contains(subreddit.Members, username) && !e.isModerator(subreddit, username) {
+		return fmt.Errorf("%s is not a member of %s", username, subreddit.Name)
+	}
+	return nil
+}
+
+func (e *Engine) setMembershipPolicy(subredditName, moderator string, policy MembershipPolicy) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	subreddit.Policy = policy
+	description := fmt.Sprintf("members-only posting %t, members-only commenting %t",
+		policy.PostRequiresMembership, policy.CommentRequiresMembership)
+	e.logModAction(subreddit, UserAction{Kind: ActionSetPolicy, Actor: moderator, Subreddit: subredditName, Content: description})
+	return nil
+}
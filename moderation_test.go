@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRemovePostRequiresModerator(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.removePost("Post 1", "bob", 0, "spam"); err == nil {
+		t.Fatal("a non-moderator removed a post")
+	}
+	if post := e.posts["Post 1"]; post.RemovedBy != "" {
+		t.Fatalf("post was removed by %s", post.RemovedBy)
+	}
+	if err := e.removePost("Post 1", "alice", 0, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if post := e.posts["Post 1"]; post.RemovedBy != "alice" {
+		t.Fatalf("post RemovedBy = %q, want alice", post.RemovedBy)
+	}
+}
+
+func TestExpiredBanIsLifted(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.banUser("r/test", "alice", "bob", "spam", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	subreddit := e.subreddits["r/test"]
+	if e.activeRestriction(subreddit.Banned, "bob") == nil {
+		t.Fatal("ban is not active")
+	}
+	subreddit.Banned["bob"].Expires = time.Now().Add(-time.Second)
+	if e.activeRestriction(subreddit.Banned, "bob") != nil {
+		t.Fatal("expired ban is still active")
+	}
+	if _, exists := subreddit.Banned["bob"]; exists {
+		t.Fatal("expired ban was not lifted")
+	}
+}
+
+func TestBannedUserCannotVote(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.banUser("r/test", "alice", "bob", "spam", 0); err != nil {
+		t.Fatal(err)
+	}
+	upvotes := e.posts["Post 1"].Upvotes
+	if err := e.vote("Post 1", "bob", true); err == nil {
+		t.Fatal("banned user voted")
+	}
+	if e.posts["Post 1"].Upvotes != upvotes {
+		t.Fatal("banned user's vote was counted")
+	}
+}
+
+func TestModLogReturnsCopies(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.removePost("Post 1", "alice", 0, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	modLog, err := e.getModLog(&GetModLog{SubredditName: "r/test", Moderator: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modLog.Entries) != 1 {
+		t.Fatalf("mod log has %d entries, want 1", len(modLog.Entries))
+	}
+	modLog.Entries[0].Reason = "tampered"
+	if reason := e.subreddits["r/test"].ModLog[0].Reason; reason != "spam" {
+		t.Fatalf("engine's mod log reason = %q after changing the response", reason)
+	}
+}
+
+func TestModLogTargets(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.updateSubredditInfo("r/test", "alice", "About testing", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.banUser("r/test", "alice", "bob", "spam", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.removePost("Post 1", "alice", 0, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	var targets []string
+	for _, entry := range e.subreddits["r/test"].ModLog {
+		targets = append(targets, entry.Target)
+	}
+	want := []string{"r/test", "bob", "Post 1"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("mod log targets = %q, want %q", targets, want)
+	}
+}
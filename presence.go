@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/asynkron/protoactor-go/actor"
+)
+
+// connect marks the user online at client and sends them everything that
+// reached their inbox while they were away.
+func (e *Engine) connect(username string, client *actor.PID) error {
+	user, exists := e.users[username]
+	if !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	if client == nil {
+		return fmt.Errorf("%s connected without a client", username)
+	}
+	e.presence[username] = client
+	if len(user.PendingDelivery) == 0 {
+		return nil
+	}
+	delivery := &Delivery{Username: username, Backlog: true}
+	for _, item := range user.PendingDelivery {
+		delivery.Items = append(delivery.Items, inboxView(item))
+	}
+	user.PendingDelivery = nil
+	e.context.Send(client, delivery)
+	return nil
+}
+
+func (e *Engine) disconnect(username string) error {
+	if _, exists := e.users[username]; !exists {
+		return fmt.Errorf("user %s does not exist", username)
+	}
+	delete(e.presence, username)
+	return nil
+}
+
+// push sends a new inbox item straight to the user's client, or queues it
+// until they next connect.
+func (e *Engine) push(user *User, item *InboxItem) {
+	client, online := e.presence[user.Username]
+	if !online {
+		user.PendingDelivery = append(user.PendingDelivery, item)
+		return
+	}
+	e.context.Send(client, &Delivery{Username: user.Username, Items: []InboxItem{inboxView(item)}})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asynkron/protoactor-go/actor"
+)
+
+func TestConnectDeliversBacklog(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "bob", "question"); err != nil {
+		t.Fatal(err)
+	}
+	// bob is offline, so both of these wait for him to connect
+	if err := e.createComment("Post 1", "Comment 1", "Comment 2", "alice", "answer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.sendDirectMessage(&SendDirectMessage{MessageID: "DM 1", From: "alice", To: "bob", Content: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(e.users["bob"].PendingDelivery); got != 2 {
+		t.Fatalf("bob has %d pending items, want 2", got)
+	}
+
+	system := actor.NewActorSystem()
+	enginePID := system.Root.Spawn(actor.PropsFromProducer(func() actor.Actor { return e }))
+	defer system.Root.Stop(enginePID)
+	client := actor.NewFuture(system, time.Second)
+	result, err := system.Root.RequestFuture(enginePID, &Connect{Username: "bob", Client: client.PID()}, time.Second).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connected, ok := result.(*ActionResult); !ok || !connected.Success {
+		t.Fatalf("connect failed: %+v", result)
+	}
+	message, err := client.Result()
+	if err != nil {
+		t.Fatalf("no backlog delivered: %v", err)
+	}
+	delivery, ok := message.(*Delivery)
+	if !ok || !delivery.Backlog || delivery.Username != "bob" || len(delivery.Items) != 2 {
+		t.Fatalf("connect delivered %+v", message)
+	}
+	if delivery.Items[0].CommentID != "Comment 2" || delivery.Items[1].MessageID != "DM 1" {
+		t.Errorf("backlog items out of order: %+v", delivery.Items)
+	}
+	if got := len(e.users["bob"].PendingDelivery); got != 0 {
+		t.Errorf("%d items still pending after delivery", got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// recommendSubreddits suggests subreddits the user has not joined. Every
+// user sharing a subscription with them counts as a neighbour weighted by the
+// Jaccard similarity of their subscriptions, and a subreddit scores the summed
+// weight of the neighbours in it. Users without subscriptions get the largest
+// subreddits instead.
+func (e *Engine) recommendSubreddits(query *RecommendSubreddits) (*SubredditRecommendations, error) {
+	user, exists := e.users[query.Username]
+	if !exists {
+		return nil, fmt.Errorf("user %s does not exist", query.Username)
+	}
+	joined := make(map[string]bool)
+	for _, subredditName := range user.SubscribedSubreddits {
+		joined[subredditName] = true
+	}
+
+	shared := make(map[string]int)
+	for subredditName := range joined {
+		for _, member := range e.subreddits[subredditName].Members {
+			if member != user.Username {
+				shared[member]++
+			}
+		}
+	}
+	scores := make(map[string]float64)
+	for member, common := range shared {
+		neighbour, exists := e.users[member]
+		if !exists {
+			continue
+		}
+		union := len(joined) + len(neighbour.SubscribedSubreddits) - common
+		similarity := float64(common) / float64(union)
+		for _, subredditName := range neighbour.SubscribedSubreddits {
+			if !joined[subredditName] {
+				scores[subredditName] += similarity
+			}
+		}
+	}
+	if len(joined) == 0 {
+		for _, subreddit := range e.subreddits {
+			scores[subreddit.Name] = float64(len(subreddit.Members))
+		}
+	}
+
+	var recommendations []SubredditRecommendation
+	for subredditName, score := range scores {
+		subreddit, exists := e.subreddits[subredditName]
+		if !exists || contains(subreddit.Members, user.Username) {
+			continue
+		}
+		if e.activeRestriction(subreddit.Banned, user.Username) != nil || e.checkCanView(subreddit, user.Username) != nil {
+			continue
+		}
+		recommendations = append(recommendations, SubredditRecommendation{Name: subredditName, Score: score, MemberCount: len(subreddit.Members)})
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Name < recommendations[j].Name
+	})
+	if query.Limit > 0 && len(recommendations) > query.Limit {
+		recommendations = recommendations[:query.Limit]
+	}
+	return &SubredditRecommendations{Username: user.Username, Subreddits: recommendations}, nil
+}
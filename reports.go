@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// reportKey identifies a reported post or comment. Comment IDs are only unique
+// within a post, so comments are keyed by both.
+func reportKey(targetType ReportTargetType, postID, commentID string) string {
+	if targetType == ReportComment {
+		return "comment:" + postID + "/" + commentID
+	}
+	return "post:" + postID
+}
+
+// messageReportKey identifies a reported direct message in the site-wide
+// queue.
+func messageReportKey(messageID string) string {
+	return "message:" + messageID
+}
+
+func (e *Engine) report(msg *Report) error {
+	if _, exists := e.users[msg.Reporter]; !exists {
+		return fmt.Errorf("user %s does not exist", msg.Reporter)
+	}
+
+	item := &ReportedItem{TargetType: msg.TargetType}
+	switch msg.TargetType {
+	case ReportPost, ReportComment:
+		post, exists := e.posts[msg.PostID]
+		if !exists || post.RemovedBy != "" || post.Deleted {
+			return fmt.Errorf("post %s does not exist", msg.PostID)
+		}
+		item.SubredditName = post.SubredditName
+		item.PostID = post.ID
+		item.Author = post.Author
+		if msg.TargetType == ReportComment {
+			comment := findComment(post.Comments, msg.CommentID)
+			if comment == nil || comment.RemovedBy != "" || comment.Deleted {
+				return fmt.Errorf("comment %s does not exist on post %s", msg.CommentID, msg.PostID)
+			}
+			item.CommentID = comment.ID
+			item.Author = comment.Author
+		}
+	case ReportMessage:
+		message, exists := e.directMessages[msg.MessageID]
+		if !exists || message.To != msg.Reporter {
+			return fmt.Errorf("message %s was not received by %s", msg.MessageID, msg.Reporter)
+		}
+		item.MessageID = message.ID
+		item.Author = message.From
+	default:
+		return fmt.Errorf("unknown report target %q", msg.TargetType)
+	}
+
+	reason, ruleTitle := msg.Reason, ""
+	if msg.RuleNumber != 0 {
+		subreddit, exists := e.subreddits[item.SubredditName]
+		if !exists {
+			return fmt.Errorf("direct messages are not covered by subreddit rules")
+		}
+		cited, err := citeRule(subreddit, msg.RuleNumber, msg.Reason)
+		if err != nil {
+			return err
+		}
+		reason = cited
+		ruleTitle = subreddit.Rules[msg.RuleNumber-1].Title
+	}
+
+	// direct messages belong to no subreddit, so their reports go to the
+	// site-wide queue instead of a moderator's
+	key := reportKey(msg.TargetType, msg.PostID, msg.CommentID)
+	if msg.TargetType == ReportMessage {
+		key = messageReportKey(msg.MessageID)
+	}
+	if existing, exists := e.reports[key]; exists {
+		item = existing
+	} else {
+		e.reports[key] = item
+	}
+	for _, entry := range item.Reports {
+		if entry.Reporter == msg.Reporter {
+			return fmt.Errorf("%s already reported this %s", msg.Reporter, msg.TargetType)
+		}
+	}
+	item.Reports = append(item.Reports, ReportEntry{Reporter: msg.Reporter, RuleNumber: msg.RuleNumber, RuleTitle: ruleTitle, Reason: msg.Reason, Timestamp: time.Now()})
+
+	e.logUserAction(UserAction{Kind: ActionReport, Actor: msg.Reporter, Subreddit: item.SubredditName,
+		PostID: item.PostID, CommentID: item.CommentID, MessageID: item.MessageID, TargetUser: item.Author, Content: reason})
+	return nil
+}
+
+// reportedItemView copies a reported item for sending out of the engine.
+func reportedItemView(item *ReportedItem) ReportedItem {
+	copied := *item
+	copied.Reports = append([]ReportEntry(nil), item.Reports...)
+	return copied
+}
+
+func (e *Engine) getModQueue(query *GetModQueue) (*ModQueue, error) {
+	subreddit, err := e.moderatedSubreddit(query.SubredditName, query.Moderator)
+	if err != nil {
+		return nil, err
+	}
+	items, total, nextOffset := e.reportQueue(func(item *ReportedItem) bool {
+		return item.SubredditName == subreddit.Name
+	}, query.Offset, query.Limit)
+	return &ModQueue{SubredditName: subreddit.Name, Items: items, Total: total, NextOffset: nextOffset}, nil
+}
+
+func (e *Engine) getMessageReports(query *GetMessageReports) *MessageReports {
+	items, total, nextOffset := e.reportQueue(func(item *ReportedItem) bool {
+		return item.TargetType == ReportMessage
+	}, query.Offset, query.Limit)
+	return &MessageReports{Items: items, Total: total, NextOffset: nextOffset}
+}
+
+// reportQueue pages through copies of the reported items that pass include,
+// most reported first.
+func (e *Engine) reportQueue(include func(*ReportedItem) bool, offset, limit int) ([]ReportedItem, int, int) {
+	var items []*ReportedItem
+	for _, item := range e.reports {
+		if include(item) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if len(items[i].Reports) != len(items[j].Reports) {
+			return len(items[i].Reports) > len(items[j].Reports)
+		}
+		return items[i].Reports[0].Timestamp.Before(items[j].Reports[0].Timestamp)
+	})
+	start, end := paginate(len(items), offset, limit)
+	var page []ReportedItem
+	for _, item := range items[start:end] {
+		page = append(page, reportedItemView(item))
+	}
+	nextOffset := -1
+	if end < len(items) {
+		nextOffset = end
+	}
+	return page, len(items), nextOffset
+}
+
+func (e *Engine) approveReported(moderator, postID, commentID string) error {
+	post, exists := e.posts[postID]
+	if !exists {
+		return fmt.Errorf("post %s does not exist", postID)
+	}
+	subreddit, err := e.moderatedSubreddit(post.SubredditName, moderator)
+	if err != nil {
+		return err
+	}
+	targetType := ReportPost
+	if commentID != "" {
+		targetType = ReportComment
+	}
+	key := reportKey(targetType, postID, commentID)
+	if _, exists := e.reports[key]; !exists {
+		return fmt.Errorf("%s has no open reports", key)
+	}
+	delete(e.reports, key)
+	if commentID == "" {
+		post.Held = false
+	} else if comment := findComment(post.Comments, commentID); comment != nil {
+		comment.Held = false
+	}
+	e.logModAction(subreddit, UserAction{Kind: ActionApproveReported, Actor: moderator, Subreddit: subreddit.Name,
+		PostID: postID, CommentID: commentID})
+	return nil
+}
+
+// holdForReview queues an item that AutoModerator held back from listings.
+func (e *Engine) holdForReview(item *ReportedItem, reason string) {
+	key := reportKey(item.TargetType, item.PostID, item.CommentID)
+	if existing, exists := e.reports[key]; exists {
+		item = existing
+	} else {
+		e.reports[key] = item
+	}
+	item.Reports = append(item.Reports, ReportEntry{Reporter: autoModerator, Reason: reason, Timestamp: time.Now()})
+}
+
+// renumberReportedRules keeps the rule numbers cited by open reports in the
+// subreddit in step with its rules after rule ruleNumber was removed. Reports
+// citing the removed rule keep only its title.
+func (e *Engine) renumberReportedRules(subredditName string, ruleNumber int) {
+	for _, item := range e.reports {
+		if item.SubredditName != subredditName {
+			continue
+		}
+		for i := range item.Reports {
+			switch entry := &item.Reports[i]; {
+			case entry.RuleNumber == ruleNumber:
+				entry.RuleNumber = 0
+			case entry.RuleNumber > ruleNumber:
+				entry.RuleNumber--
+			}
+		}
+	}
+}
+
+// clearReports drops the open reports on an item once a moderator removes it.
+func (e *Engine) clearReports(targetType ReportTargetType, postID, commentID string) {
+	delete(e.reports, reportKey(targetType, postID, commentID))
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestMessageReportsAreQueuedSiteWide(t *testing.T) {
+	e := newTestEngine(t)
+	e.registerUser("carol")
+	for _, send := range []*SendDirectMessage{
+		{MessageID: "DM 1", From: "alice", To: "bob", Content: "rude"},
+		{MessageID: "DM 2", From: "alice", To: "carol", Content: "rude"},
+	} {
+		if err := e.sendDirectMessage(send); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.report(&Report{Reporter: "bob", TargetType: ReportMessage, MessageID: "DM 1", Reason: "harassment"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.report(&Report{Reporter: "bob", TargetType: ReportMessage, MessageID: "DM 1", Reason: "again"}); err == nil {
+		t.Fatal("a repeat report of the same message was accepted")
+	}
+	if err := e.report(&Report{Reporter: "carol", TargetType: ReportMessage, MessageID: "DM 2", Reason: "harassment"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reports := e.getMessageReports(&GetMessageReports{})
+	if reports.Total != 2 || len(reports.Items) != 2 {
+		t.Fatalf("site queue has %d items, want 2", reports.Total)
+	}
+	for _, item := range reports.Items {
+		if item.Author != "alice" || len(item.Reports) != 1 {
+			t.Errorf("reported message %s: author %s with %d reports", item.MessageID, item.Author, len(item.Reports))
+		}
+	}
+	queue, err := e.getModQueue(&GetModQueue{SubredditName: "r/test", Moderator: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queue.Total != 0 {
+		t.Fatalf("subreddit mod queue holds %d message reports", queue.Total)
+	}
+}
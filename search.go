@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// searchIndex is an inverted index over post titles and contents and comment
+// contents. Documents are keyed like reports: "post:<id>" and
+// "comment:<post id>/<comment id>".
+type searchIndex struct {
+	postings map[string]map[string]int // term to document key to term frequency
+	docs     map[string]*searchDoc
+}
+
+type searchDoc struct {
+	PostID    string
+	CommentID string
+	Terms     []string // in document order, for phrase matching
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]int),
+		docs:     make(map[string]*searchDoc),
+	}
+}
+
+// tokenize lowercases text and splits it into runs of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// add indexes text under key, replacing whatever was indexed there before.
+func (idx *searchIndex) add(key, postID, commentID, text string) {
+	idx.remove(key)
+	doc := &searchDoc{PostID: postID, CommentID: commentID, Terms: tokenize(text)}
+	idx.docs[key] = doc
+	for _, term := range doc.Terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = make(map[string]int)
+		}
+		idx.postings[term][key]++
+	}
+}
+
+func (idx *searchIndex) remove(key string) {
+	doc, exists := idx.docs[key]
+	if !exists {
+		return
+	}
+	for _, term := range doc.Terms {
+		delete(idx.postings[term], key)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docs, key)
+}
+
+func (e *Engine) indexPost(post *Post) {
+	e.index.add(reportKey(ReportPost, post.ID, ""), post.ID, "", post.Title+"\n"+post.Content)
+}
+
+func (e *Engine) indexComment(postID string, comment *Comment) {
+	e.index.add(reportKey(ReportComment, postID, comment.ID), postID, comment.ID, comment.Content)
+}
+
+// parseSearchQuery splits a query into single terms and double-quoted
+// phrases. An unterminated quote runs to the end of the query.
+func parseSearchQuery(query string) (terms []string, phrases [][]string) {
+	for i, part := range strings.Split(query, `"`) {
+		words := tokenize(part)
+		// a quoted single word is just a term
+		if i%2 == 1 && len(words) > 1 {
+			phrases = append(phrases, words)
+		} else {
+			terms = append(terms, words...)
+		}
+	}
+	return terms, phrases
+}
+
+// containsPhrase reports whether phrase occurs as consecutive terms.
+func containsPhrase(terms, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(terms); i++ {
+		matched := true
+		for j, word := range phrase {
+			if terms[i+j] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingDocs returns the keys of documents containing every word, scored by
+// the sum of tf-idf over the words.
+func (idx *searchIndex) matchingDocs(words []string) map[string]float64 {
+	scores := make(map[string]float64)
+	for i, word := range words {
+		postings := idx.postings[word]
+		idf := math.Log(1 + float64(len(idx.docs))/float64(len(postings)+1))
+		if i == 0 {
+			for key, frequency := range postings {
+				scores[key] = float64(frequency) * idf
+			}
+			continue
+		}
+		for key := range scores {
+			frequency, exists := postings[key]
+			if !exists {
+				delete(scores, key)
+				continue
+			}
+			scores[key] += float64(frequency) * idf
+		}
+	}
+	return scores
+}
+
+func (e *Engine) search(query *Search) (*SearchResults, error) {
+	terms, phrases := parseSearchQuery(query.Query)
+	words := append([]string(nil), terms...)
+	for _, phrase := range phrases {
+		words = append(words, phrase...)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("search query %q has no terms", query.Query)
+	}
+	if query.SubredditName != "" {
+		subreddit, exists := e.subreddits[query.SubredditName]
+		if !exists {
+			return nil, fmt.Errorf("subreddit %s does not exist", query.SubredditName)
+		}
+		if err := e.checkCanView(subreddit, query.Username); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []SearchResult
+	for key, score := range e.index.matchingDocs(words) {
+		doc := e.index.docs[key]
+		matched := true
+		for _, phrase := range phrases {
+			if !containsPhrase(doc.Terms, phrase) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if result, visible := e.searchResult(doc, query); visible {
+			result.Score = score
+			results = append(results, result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].PostID != results[j].PostID {
+			return results[i].PostID < results[j].PostID
+		}
+		return results[i].CommentID < results[j].CommentID
+	})
+
+	start, end := paginate(len(results), query.Offset, query.Limit)
+	page := &SearchResults{Results: results[start:end], Total: len(results), NextOffset: -1}
+	if end < len(results) {
+		page.NextOffset = end
+	}
+	return page, nil
+}
+
+// searchResult resolves an indexed document, reporting false when it no
+// longer shows up for the searcher or does not pass the query's filters.
+func (e *Engine) searchResult(doc *searchDoc, query *Search) (SearchResult, bool) {
+	post, exists := e.posts[doc.PostID]
+	if !exists || !isListed(post, "") {
+		return SearchResult{}, false
+	}
+	if query.SubredditName != "" && post.SubredditName != query.SubredditName {
+		return SearchResult{}, false
+	}
+	subreddit := e.subreddits[post.SubredditName]
+	if e.checkCanView(subreddit, query.Username) != nil {
+		return SearchResult{}, false
+	}
+	result := SearchResult{PostID: post.ID, SubredditName: post.SubredditName, Author: post.Author, Title: post.Title, Content: post.Content}
+	if doc.CommentID != "" {
+		comment := findComment(post.Comments, doc.CommentID)
+		if comment == nil || comment.RemovedBy != "" || comment.Deleted || comment.Held {
+			return SearchResult{}, false
+		}
+		result.CommentID = comment.ID
+		result.Author = comment.Author
+		result.Content = comment.Content
+	}
+	if (query.Author != "" && result.Author != query.Author) || e.hasBlocked(query.Username, result.Author) {
+		return SearchResult{}, false
+	}
+	return result, true
+}
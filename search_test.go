@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchFindsNestedReplies(t *testing.T) {
+	e := newTestEngine(t)
+	if err := e.createComment("Post 1", "Post 1", "Comment 1", "bob", "top level"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.createComment("Post 1", "Comment 1", "Comment 2", "alice", "a nested zebra"); err != nil {
+		t.Fatal(err)
+	}
+	results, err := e.search(&Search{Query: "zebra", Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || results.Results[0].CommentID != "Comment 2" || results.Results[0].Content != "a nested zebra" {
+		t.Fatalf("search for a nested reply returned %+v", results.Results)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		terms   []string
+		phrases [][]string
+	}{
+		{"", nil, nil},
+		{"Hello, World!", []string{"hello", "world"}, nil},
+		{`"simulated comment" deals`, []string{"deals"}, [][]string{{"simulated", "comment"}}},
+		{`"single"`, []string{"single"}, nil},
+		{`a "b c`, []string{"a"}, [][]string{{"b", "c"}}}, // unterminated quote
+		{`"a b" "c d"`, nil, [][]string{{"a", "b"}, {"c", "d"}}},
+	}
+	for _, tt := range tests {
+		terms, phrases := parseSearchQuery(tt.query)
+		if !reflect.DeepEqual(terms, tt.terms) || !reflect.DeepEqual(phrases, tt.phrases) {
+			t.Errorf("parseSearchQuery(%q) = %q, %q, want %q, %q", tt.query, terms, phrases, tt.terms, tt.phrases)
+		}
+	}
+}
+
+func TestContainsPhrase(t *testing.T) {
+	terms := []string{"a", "b", "c"}
+	tests := []struct {
+		phrase []string
+		want   bool
+	}{
+		{[]string{"a", "b"}, true},
+		{[]string{"b", "c"}, true},
+		{[]string{"a", "b", "c"}, true},
+		{[]string{"c", "b"}, false},
+		{[]string{"a", "c"}, false},
+		{[]string{"b", "c", "d"}, false}, // runs past the end
+		{[]string{"a", "b", "c", "d"}, false},
+	}
+	for _, tt := range tests {
+		if got := containsPhrase(terms, tt.phrase); got != tt.want {
+			t.Errorf("containsPhrase(%q) = %v, want %v", tt.phrase, got, tt.want)
+		}
+	}
+}
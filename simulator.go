@@ -3,11 +3,23 @@ package main
 import (
 	"fmt"
 	"math/rand"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/asynkron/protoactor-go/actor"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// simulatedRules are given to every simulated subreddit, so reports and
+// removals can cite them by number.
+var simulatedRules = []SubredditRule{
+	{Title: "No spam", Description: "Self-promotion and link farming are removed."},
+	{Title: "Stay on topic", Description: "Posts must relate to the community."},
+}
+
+var simulatedFlairs = []string{"Discussion", "News", "Meme"}
+
 type Simulator struct {
 	enginePID          *actor.PID
 	users              []string
@@ -17,13 +29,27 @@ type Simulator struct {
 	actions            int
 	context            actor.Context
 	comments           map[string][]string
+	moderators         map[string][]string
+	members            map[string][]string
+	postSubreddits     map[string]string
+	authors            map[string]string // post ID, or post ID/comment ID, to author
+	messages           []*DirectMessage
 	zipf               *rand.Zipf
 	MAX_USERS          int
 	MAX_SUBREDDITS     int
 	SIMULATION_ACTIONS int
+	jsonActions        bool
+	actionSpan         trace.Span // root span of the action in progress
+	// deliveries are received on the actor while the simulation runs in its
+	// own goroutine
+	deliveryMu     sync.Mutex
+	deliveries     int
+	backlogItems   int
+	deliveryDelay  time.Duration
+	maxDeliveryLag time.Duration
 }
 
-func NewSimulator(enginePID *actor.PID, maxUsers, maxSubreddits, simulationActions int) actor.Actor {
+func NewSimulator(enginePID *actor.PID, maxUsers, maxSubreddits, simulationActions int, jsonActions bool) actor.Actor {
 
 	eh := rand.NewSource(time.Now().UnixNano())
 	r := rand.New(eh)
@@ -31,23 +57,60 @@ func NewSimulator(enginePID *actor.PID, maxUsers, maxSubreddits, simulationActio
 	return &Simulator{
 		enginePID:          enginePID,
 		comments:           make(map[string][]string),
+		moderators:         make(map[string][]string),
+		members:            make(map[string][]string),
+		postSubreddits:     make(map[string]string),
+		authors:            make(map[string]string),
 		userStatus:         make(map[string]bool),
 		actions:            0,
 		zipf:               zipf,
 		MAX_USERS:          maxUsers,
 		MAX_SUBREDDITS:     maxSubreddits,
 		SIMULATION_ACTIONS: simulationActions,
+		jsonActions:        jsonActions,
 	}
 }
 
 func (s *Simulator) Receive(context actor.Context) {
-	switch context.Message().(type) {
+	switch msg := context.Message().(type) {
 	case *actor.Started:
 		s.context = context
 		fmt.Println("Simulator started")
 		go s.runSimulation(context)
+	case *Delivery:
+		s.recordDelivery(msg)
+	}
+}
+
+// recordDelivery measures how long pushed inbox items took to reach their
+// recipient, including time spent queued while the recipient was offline.
+func (s *Simulator) recordDelivery(delivery *Delivery) {
+	now := time.Now()
+	s.deliveryMu.Lock()
+	defer s.deliveryMu.Unlock()
+	for _, item := range delivery.Items {
+		delay := now.Sub(item.Created)
+		s.deliveries++
+		s.deliveryDelay += delay
+		if delay > s.maxDeliveryLag {
+			s.maxDeliveryLag = delay
+		}
+	}
+	if delivery.Backlog {
+		s.backlogItems += len(delivery.Items)
 	}
 }
+
+func (s *Simulator) printDeliveryStats() {
+	s.deliveryMu.Lock()
+	defer s.deliveryMu.Unlock()
+	if s.deliveries == 0 {
+		fmt.Println("No inbox items delivered.")
+		return
+	}
+	fmt.Printf("Delivered %d inbox items (%d from offline backlogs), average delay %s, max %s.\n",
+		s.deliveries, s.backlogItems, s.deliveryDelay/time.Duration(s.deliveries), s.maxDeliveryLag)
+}
 func (s *Simulator) runSimulation(context actor.Context) {
 	startTime := time.Now()
 	s.registerInitialUsers(context)
@@ -64,6 +127,7 @@ func (s *Simulator) runSimulation(context actor.Context) {
 		s.printSimulationStats(context)
 	}
 
+	s.printDeliveryStats()
 	endTime := time.Now()
 	fmt.Printf("Simulation completed in %s.\n", endTime.Sub(startTime))
 
@@ -82,10 +146,19 @@ func (s *Simulator) createInitialSubreddits(context actor.Context) {
 }
 
 func (s *Simulator) simulateAction(context actor.Context) {
-	action := rand.Intn(8)
+	s.actionSpan = startActionSpan(s.actions)
+	defer func() {
+		s.actionSpan.End()
+		s.actionSpan = nil
+	}()
+	action := rand.Intn(12)
 	switch action {
 	case 0:
-		s.simulateJoinSubreddit(context)
+		if rand.Intn(4) == 0 {
+			s.simulateDiscoverSubreddit(context)
+		} else {
+			s.simulateRecommendedJoin(context)
+		}
 	case 1:
 		s.simulateLeaveSubreddit(context)
 	case 2:
@@ -95,23 +168,41 @@ func (s *Simulator) simulateAction(context actor.Context) {
 	case 4:
 		s.simulateVote(context)
 	case 5:
-		s.simulateSendDirectMessage(context)
+		switch rand.Intn(4) {
+		case 0:
+			s.simulateReadMessages(context)
+		case 1:
+			s.simulateReadInbox(context)
+		default:
+			s.simulateSendDirectMessage(context)
+		}
 	case 6:
 		s.simulateGetFeed(context)
 	case 7:
-		s.simulateConnection()
+		s.simulateConnection(context)
+	case 8:
+		s.simulateModeration(context)
+	case 9:
+		s.simulateReport(context)
+	case 10:
+		s.simulateEdit(context)
+	case 11:
+		s.simulateSearch(context)
 	}
 }
 
-func (s *Simulator) simulateConnection() {
+func (s *Simulator) simulateConnection(context actor.Context) {
 	username := s.randomUser()
 	if rand.Intn(2) == 0 {
 		s.userStatus[username] = true
+		// the simulator stands in for every user's client
+		s.send(context, &Connect{Username: username, Client: context.Self()})
 		if s.MAX_USERS < 50 {
 			fmt.Printf("%s is now connected.\n", username)
 		}
 	} else {
 		s.userStatus[username] = false
+		s.send(context, &Disconnect{Username: username})
 		if s.MAX_USERS < 50 {
 			fmt.Printf("%s is now disconnected.\n", username)
 		}
@@ -121,14 +212,44 @@ func (s *Simulator) simulateConnection() {
 func (s *Simulator) simulateRegisterUser(context actor.Context) {
 	username := fmt.Sprintf("User %d", len(s.users)+1)
 	s.users = append(s.users, username)
-	context.Send(s.enginePID, &RegisterUser{Username: username})
+	s.send(context, &RegisterUser{Username: username})
 }
 
 func (s *Simulator) simulateCreateSubreddit(context actor.Context) {
 	subredditName := fmt.Sprintf("r/Sub %d", len(s.subreddits)+1)
 	creator := s.randomUser()
 	s.subreddits = append(s.subreddits, subredditName)
-	context.Send(s.enginePID, &CreateSubreddit{Name: subredditName, Creator: creator})
+	s.moderators[subredditName] = []string{creator}
+	s.members[subredditName] = []string{creator}
+	subredditType := SubredditPublic
+	switch rand.Intn(10) {
+	case 0:
+		subredditType = SubredditPrivate
+	case 1, 2:
+		subredditType = SubredditRestricted
+	}
+	s.send(context, &CreateSubreddit{Name: subredditName, Creator: creator, Type: subredditType})
+	s.send(context, &UpdateSubredditInfo{
+		SubredditName: subredditName,
+		Moderator:     creator,
+		Description:   fmt.Sprintf("Simulated community number %d", len(s.subreddits)),
+		Sidebar:       "Be nice and stay on topic.",
+	})
+	for _, rule := range simulatedRules {
+		s.send(context, &AddSubredditRule{SubredditName: subredditName, Moderator: creator, Rule: rule})
+	}
+	for _, flair := range simulatedFlairs {
+		s.send(context, &AddPostFlair{SubredditName: subredditName, Moderator: creator, Flair: flair})
+	}
+	// some communities block a spam domain and tag posts from zero-karma users
+	if rand.Intn(2) == 0 {
+		s.send(context, &AddAutoModRule{SubredditName: subredditName, Moderator: creator, Rule: AutoModRule{
+			Name: "spam links", BlockedDomains: []string{"spam.example"}, Action: AutoModRemove,
+		}})
+		s.send(context, &AddAutoModRule{SubredditName: subredditName, Moderator: creator, Rule: AutoModRule{
+			Name: "new accounts", MinKarma: 1, Action: AutoModTag, Tag: "new user",
+		}})
+	}
 }
 
 func (s *Simulator) simulateJoinSubreddit(context actor.Context) {
@@ -138,10 +259,15 @@ func (s *Simulator) simulateJoinSubreddit(context actor.Context) {
 		for i := 0; i < memberCount; i++ {
 			user := s.randomUser()
 			if s.userStatus[user] {
-				context.Send(s.enginePID, &JoinSubreddit{
+				// the engine turns away banned users and non-approved users of
+				// private subreddits, so only count confirmed joins
+				result, err := s.request(context, &JoinSubreddit{
 					SubredditName: subreddit,
 					Username:      user,
 				})
+				if joined, ok := result.(*ActionResult); err == nil && ok && joined.Success && !contains(s.members[subreddit], user) {
+					s.members[subreddit] = append(s.members[subreddit], user)
+				}
 			} else {
 				continue
 			}
@@ -150,10 +276,68 @@ func (s *Simulator) simulateJoinSubreddit(context actor.Context) {
 	}
 }
 
+// simulateRecommendedJoin has a connected user join the subreddit the engine
+// recommends to them, so communities grow through overlapping memberships.
+// When the user is offline or has no recommendation it falls back to Zipf
+// distributed joins, which keep subreddit sizes skewed.
+func (s *Simulator) simulateRecommendedJoin(context actor.Context) {
+	username := s.randomUser()
+	if !s.userStatus[username] {
+		s.simulateJoinSubreddit(context)
+		return
+	}
+	result, err := s.request(context, &RecommendSubreddits{Username: username, Limit: 1})
+	recommendations, ok := result.(*SubredditRecommendations)
+	if err != nil || !ok || len(recommendations.Subreddits) == 0 {
+		s.simulateJoinSubreddit(context)
+		return
+	}
+	subredditName := recommendations.Subreddits[0].Name
+	result, err = s.request(context, &JoinSubreddit{SubredditName: subredditName, Username: username})
+	if joined, ok := result.(*ActionResult); err == nil && ok && joined.Success && !contains(s.members[subredditName], username) {
+		s.members[subredditName] = append(s.members[subredditName], username)
+	}
+}
+
+// simulateDiscoverSubreddit has a connected user browse the directory, at
+// times with a mistyped name, and join the first subreddit they are not in.
+func (s *Simulator) simulateDiscoverSubreddit(context actor.Context) {
+	username := s.randomUser()
+	if !s.userStatus[username] {
+		return
+	}
+	sorts := []DirectorySort{DirectoryByMembers, DirectoryByActivity, DirectoryByNewest}
+	query := &SearchSubreddits{Username: username, Sort: sorts[rand.Intn(len(sorts))], Limit: 5}
+	if rand.Intn(2) == 0 {
+		query.Query = "sbu"
+	}
+	result, err := s.request(context, query)
+	if err != nil {
+		return
+	}
+	directory, ok := result.(*SubredditDirectory)
+	if !ok {
+		return
+	}
+	for _, entry := range directory.Subreddits {
+		if contains(s.members[entry.Name], username) {
+			continue
+		}
+		result, err := s.request(context, &JoinSubreddit{SubredditName: entry.Name, Username: username})
+		if joined, ok := result.(*ActionResult); err == nil && ok && joined.Success {
+			s.members[entry.Name] = append(s.members[entry.Name], username)
+		}
+		return
+	}
+}
+
 func (s *Simulator) simulateLeaveSubreddit(context actor.Context) {
-	context.Send(s.enginePID, &LeaveSubreddit{
-		SubredditName: s.randomSubreddit(),
-		Username:      s.randomUser(),
+	subredditName := s.randomSubreddit()
+	username := s.randomUser()
+	s.members[subredditName] = remove(s.members[subredditName], username)
+	s.send(context, &LeaveSubreddit{
+		SubredditName: subredditName,
+		Username:      username,
 	})
 }
 
@@ -173,35 +357,97 @@ func (s *Simulator) simulateCreatePost(context actor.Context) {
 	}
 
 	subredditName := s.subreddits[subredditIndex]
+	// subreddits only accept posts from their members
+	members := s.members[subredditName]
+	if len(members) == 0 {
+		return
+	}
 
 	postID := fmt.Sprintf("Post %d", len(s.posts)+1)
-	s.posts = append(s.posts, postID)
-
-	context.Send(s.enginePID, &CreatePost{
+	content := fmt.Sprintf("Hello there! This is content of %s", postID)
+	if rand.Intn(10) == 0 {
+		content += " Check out http://spam.example/deals"
+	}
+	author := members[rand.Intn(len(members))]
+	// restricted and private subreddits turn away members who are not
+	// approved, so only track posts the engine accepted
+	result, err := s.request(context, &CreatePost{
 		PostID:        postID,
 		SubredditName: subredditName,
-		Author:        s.randomUser(),
-		Title:         fmt.Sprintf("Post %d", len(s.posts)),
-		Content:       fmt.Sprintf("Hello there! This is content of %s", postID),
+		Author:        author,
+		Title:         postID,
+		Content:       content,
+		Flair:         simulatedFlairs[rand.Intn(len(simulatedFlairs))],
 	})
+	if created, ok := result.(*ActionResult); err != nil || !ok || !created.Success {
+		return
+	}
+	s.posts = append(s.posts, postID)
+	s.postSubreddits[postID] = subredditName
+	s.authors[postID] = author
+	// regular posters sometimes pick a user flair for the community
+	if rand.Intn(5) == 0 {
+		s.send(context, &SetUserFlair{SubredditName: subredditName, SetBy: author, Username: author, Flair: "Regular"})
+	}
 }
 
 func (s *Simulator) simulateCreateComment(context actor.Context) {
 	if len(s.posts) > 0 {
 		postID := s.randomPost()
+		members := s.members[s.postSubreddits[postID]]
+		if len(members) == 0 {
+			return
+		}
 		parentID := postID
 		if rand.Float32() < 0.5 && len(s.posts) > 0 {
 			parentID = s.randomComment(postID)
 		}
 		commentID := fmt.Sprintf("Comment %d", len(s.comments[postID])+1)
-		s.comments[postID] = append(s.comments[postID], commentID)
-		context.Send(s.enginePID, &CreateComment{
+		author := members[rand.Intn(len(members))]
+		content := fmt.Sprintf("This is a simulated %s.", commentID)
+		if rand.Intn(5) == 0 {
+			content += " cc u/" + strings.ReplaceAll(s.randomUser(), " ", "_")
+		}
+		// like posts, comments can be turned away by the subreddit's policy,
+		// a lock or a block
+		result, err := s.request(context, &CreateComment{
 			PostID:    postID,
 			ParentID:  parentID,
 			CommentID: commentID,
-			Author:    s.randomUser(),
-			Content:   fmt.Sprintf("This is a simulated %s.", commentID),
+			Author:    author,
+			Content:   content,
 		})
+		if created, ok := result.(*ActionResult); err != nil || !ok || !created.Success {
+			return
+		}
+		s.comments[postID] = append(s.comments[postID], commentID)
+		s.authors[postID+"/"+commentID] = author
+	}
+}
+
+// simulateEdit has the author of a random post or comment edit it, or now and
+// then delete it.
+func (s *Simulator) simulateEdit(context actor.Context) {
+	if len(s.posts) == 0 {
+		return
+	}
+	postID := s.randomPost()
+	commentID := s.randomComment(postID)
+	deleting := rand.Intn(5) == 0
+	if commentID == postID {
+		author := s.authors[postID]
+		if deleting {
+			s.send(context, &DeletePost{PostID: postID, Author: author})
+		} else {
+			s.send(context, &EditPost{PostID: postID, Author: author, Content: fmt.Sprintf("Edited content of %s", postID)})
+		}
+		return
+	}
+	author := s.authors[postID+"/"+commentID]
+	if deleting {
+		s.send(context, &DeleteComment{PostID: postID, CommentID: commentID, Author: author})
+	} else {
+		s.send(context, &EditComment{PostID: postID, CommentID: commentID, Author: author, Content: fmt.Sprintf("This is an edited %s.", commentID)})
 	}
 }
 
@@ -214,7 +460,7 @@ func (s *Simulator) randomComment(postID string) string {
 
 func (s *Simulator) simulateVote(context actor.Context) {
 	if len(s.posts) > 0 {
-		context.Send(s.enginePID, &Vote{
+		s.send(context, &Vote{
 			PostID:   s.randomPost(),
 			UserID:   s.randomUser(),
 			IsUpvote: rand.Intn(2) == 0,
@@ -229,21 +475,296 @@ func (s *Simulator) simulateSendDirectMessage(context actor.Context) {
 		to = s.randomUser()
 	}
 	//First message
-	context.Send(s.enginePID, &SendDirectMessage{
-		From:    from,
-		To:      to,
-		Content: fmt.Sprintf("This is a direct message from %s to %s", from, to),
-	})
+	first := s.sendDirectMessage(context, from, to, fmt.Sprintf("This is a direct message from %s to %s", from, to), "")
 	//Reply to the message
-	context.Send(s.enginePID, &SendDirectMessage{
-		From:    to,
-		To:      from,
-		Content: fmt.Sprintf("This is a reply message from %s to %s", to, from),
-	})
+	s.sendDirectMessage(context, to, from, fmt.Sprintf("This is a reply message from %s to %s", to, from), first.ID)
+}
+
+func (s *Simulator) sendDirectMessage(context actor.Context, from, to, content, replyTo string) *DirectMessage {
+	message := &DirectMessage{ID: fmt.Sprintf("DM %d", len(s.messages)+1), ReplyTo: replyTo, From: from, To: to, Content: content}
+	s.messages = append(s.messages, message)
+	s.send(context, &SendDirectMessage{MessageID: message.ID, ReplyTo: replyTo, From: from, To: to, Content: content})
+	return message
+}
+
+// simulateReadInbox has a user look at their unread inbox and either mark
+// everything read or just the newest item.
+func (s *Simulator) simulateReadInbox(context actor.Context) {
+	username := s.randomUser()
+	if rand.Intn(3) == 0 {
+		s.simulateReadNotifications(context, username)
+		return
+	}
+	result, err := s.request(context, &GetInbox{Username: username, UnreadOnly: true, Limit: 10})
+	inbox, ok := result.(*Inbox)
+	if err != nil || !ok || len(inbox.Items) == 0 {
+		return
+	}
+	if rand.Intn(2) == 0 {
+		s.send(context, &MarkAllInboxRead{Username: username})
+	} else {
+		s.send(context, &MarkInboxRead{Username: username, ItemIDs: []string{inbox.Items[0].ID}})
+	}
+}
+
+// simulateReadNotifications has a user check their replies and mentions;
+// users buried in notifications turn comment reply notifications off.
+func (s *Simulator) simulateReadNotifications(context actor.Context, username string) {
+	result, err := s.request(context, &GetNotifications{Username: username, UnreadOnly: true})
+	notifications, ok := result.(*Notifications)
+	if err != nil || !ok {
+		return
+	}
+	if notifications.Unread > 5 && notifications.Preferences.CommentReplies {
+		preferences := notifications.Preferences
+		preferences.CommentReplies = false
+		s.send(context, &SetNotificationPreferences{Username: username, Preferences: preferences})
+	}
+	var itemIDs []string
+	for _, item := range notifications.Items {
+		itemIDs = append(itemIDs, item.ID)
+	}
+	if len(itemIDs) > 0 {
+		s.send(context, &MarkInboxRead{Username: username, ItemIDs: itemIDs})
+	}
+}
+
+// simulateReadMessages has a user open their most recent conversation and
+// answer its last message if it was sent to them.
+func (s *Simulator) simulateReadMessages(context actor.Context) {
+	username := s.randomUser()
+	result, err := s.request(context, &GetConversations{Username: username, Limit: 1})
+	list, ok := result.(*ConversationList)
+	if err != nil || !ok || len(list.Conversations) == 0 {
+		return
+	}
+	result, err = s.request(context, &GetConversation{ConversationID: list.Conversations[0].ID, Username: username, Limit: 20})
+	conversation, ok := result.(*ConversationMessages)
+	if err != nil || !ok || len(conversation.Messages) == 0 {
+		return
+	}
+	last := conversation.Messages[len(conversation.Messages)-1]
+	switch {
+	case last.To != username:
+	case rand.Intn(5) == 0:
+		// tired of hearing from them; a later visit may lift the block
+		s.send(context, &BlockUser{Username: username, Blocked: last.From})
+	default:
+		s.sendDirectMessage(context, username, last.From, fmt.Sprintf("This is a reply message from %s to %s", username, last.From), last.ID)
+	}
+	if rand.Intn(5) == 0 {
+		s.simulateReviewBlockList(context, username)
+	}
+}
+
+// simulateReviewBlockList has a user unblock the first user on their block
+// list.
+func (s *Simulator) simulateReviewBlockList(context actor.Context, username string) {
+	result, err := s.request(context, &GetBlockedUsers{Username: username})
+	blocked, ok := result.(*BlockedUsers)
+	if err != nil || !ok || len(blocked.Blocked) == 0 {
+		return
+	}
+	s.send(context, &UnblockUser{Username: username, Blocked: blocked.Blocked[0]})
+}
+
+// simulateReport has a random user flag a post, a comment or, as its
+// recipient, a direct message.
+func (s *Simulator) simulateReport(context actor.Context) {
+	if len(s.posts) == 0 {
+		return
+	}
+	report := &Report{Reporter: s.randomUser(), TargetType: ReportPost, PostID: s.randomPost(), RuleNumber: 1 + rand.Intn(len(simulatedRules))}
+	switch rand.Intn(3) {
+	case 1:
+		if commentID := s.randomComment(report.PostID); commentID != report.PostID {
+			report.TargetType = ReportComment
+			report.CommentID = commentID
+		}
+	case 2:
+		if len(s.messages) > 0 {
+			message := s.messages[rand.Intn(len(s.messages))]
+			report = &Report{Reporter: message.To, TargetType: ReportMessage, MessageID: message.ID, Reason: "Simulated harassment report"}
+		}
+	}
+	s.send(context, report)
+}
+
+// simulateModeration has a moderator appoint another moderator or an approved
+// user, remove a post from their subreddit, temporarily ban or mute a user, or
+// work through the mod queue.
+func (s *Simulator) simulateModeration(context actor.Context) {
+	subredditName := s.randomSubreddit()
+	moderators := s.moderators[subredditName]
+	moderator := moderators[rand.Intn(len(moderators))]
+	user := s.randomUser()
+
+	switch rand.Intn(7) {
+	case 0:
+		if contains(moderators, user) {
+			return
+		}
+		s.moderators[subredditName] = append(moderators, user)
+		s.send(context, &AddModerator{SubredditName: subredditName, Moderator: moderator, Username: user})
+	case 1:
+		if len(s.posts) == 0 {
+			return
+		}
+		postID := s.randomPost()
+		moderators = s.moderators[s.postSubreddits[postID]]
+		s.send(context, &RemovePost{PostID: postID, Moderator: moderators[rand.Intn(len(moderators))], RuleNumber: 2})
+	case 2:
+		s.members[subredditName] = remove(s.members[subredditName], user)
+		s.send(context, &BanUser{
+			SubredditName: subredditName,
+			Moderator:     moderator,
+			Username:      user,
+			Reason:        "Simulated rule violation",
+			Duration:      time.Duration(500+rand.Intn(1500)) * time.Millisecond,
+		})
+	case 3:
+		s.send(context, &MuteUser{
+			SubredditName: subredditName,
+			Moderator:     moderator,
+			Username:      user,
+			Reason:        "Simulated spam",
+			Duration:      time.Duration(500+rand.Intn(1500)) * time.Millisecond,
+		})
+	case 4:
+		s.simulateReviewModQueue(context, subredditName, moderator)
+	case 5:
+		s.send(context, &ApproveUser{SubredditName: subredditName, Moderator: moderator, Username: user})
+	case 6:
+		if len(s.posts) == 0 {
+			return
+		}
+		postID := s.randomPost()
+		moderators = s.moderators[s.postSubreddits[postID]]
+		moderator = moderators[rand.Intn(len(moderators))]
+		// pins beyond the subreddit's limit are rejected by the engine
+		if rand.Intn(2) == 0 {
+			s.send(context, &PinPost{PostID: postID, Moderator: moderator})
+		} else {
+			s.send(context, &LockPost{PostID: postID, Moderator: moderator})
+		}
+	}
+}
+
+// simulateReviewModQueue has a moderator take the most reported item in their
+// queue and either approve or remove it.
+func (s *Simulator) simulateReviewModQueue(context actor.Context, subredditName, moderator string) {
+	result, err := s.request(context, &GetModQueue{SubredditName: subredditName, Moderator: moderator, Limit: 1})
+	if err != nil {
+		return
+	}
+	queue, ok := result.(*ModQueue)
+	if !ok || len(queue.Items) == 0 {
+		return
+	}
+	item := queue.Items[0]
+	switch {
+	case rand.Intn(2) == 0:
+		s.send(context, &ApproveReported{Moderator: moderator, PostID: item.PostID, CommentID: item.CommentID})
+	case item.TargetType == ReportComment:
+		s.send(context, &RemoveComment{PostID: item.PostID, CommentID: item.CommentID, Moderator: moderator, RuleNumber: item.Reports[0].RuleNumber, Reason: item.Reports[0].Reason})
+	default:
+		s.send(context, &RemovePost{PostID: item.PostID, Moderator: moderator, RuleNumber: item.Reports[0].RuleNumber, Reason: item.Reports[0].Reason})
+	}
+}
+
+var simulatedSearches = []string{"hello", `"simulated comment"`, "edited", "deals"}
+
+// simulateSearch runs a search and upvotes the best matching post.
+func (s *Simulator) simulateSearch(context actor.Context) {
+	username := s.randomUser()
+	result, err := s.request(context, &Search{Query: simulatedSearches[rand.Intn(len(simulatedSearches))], Username: username, Limit: 10})
+	if err != nil {
+		return
+	}
+	results, ok := result.(*SearchResults)
+	if !ok || len(results.Results) == 0 {
+		return
+	}
+	s.send(context, &Vote{PostID: results.Results[0].PostID, UserID: username, IsUpvote: true})
 }
 
 func (s *Simulator) simulateGetFeed(context actor.Context) {
-	context.Send(s.enginePID, &GetFeed{Username: s.randomUser()})
+	if rand.Intn(3) == 0 {
+		s.simulateGetHomeFeed(context)
+		return
+	}
+	username := s.randomUser()
+	if !s.hasJoinedAny(username) {
+		s.simulateBrowsePopular(context, username)
+		return
+	}
+	feed := &GetFeed{Username: username}
+	if rand.Intn(4) == 0 {
+		feed.Flair = simulatedFlairs[rand.Intn(len(simulatedFlairs))]
+	}
+	s.send(context, feed)
+}
+
+func (s *Simulator) hasJoinedAny(username string) bool {
+	for _, members := range s.members {
+		if contains(members, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// simulateBrowsePopular has a user without subscriptions read r/popular, or
+// r/all sorted by top, and upvote what they like best.
+func (s *Simulator) simulateBrowsePopular(context actor.Context, username string) {
+	query := &GetGlobalListing{Listing: ListingPopular, Sort: SortHot, Limit: 10}
+	if rand.Intn(2) == 0 {
+		query = &GetGlobalListing{Listing: ListingAll, Sort: SortTop, Limit: 10}
+	}
+	result, err := s.request(context, query)
+	if err != nil {
+		return
+	}
+	listing, ok := result.(*PostListing)
+	if !ok || len(listing.Posts) == 0 {
+		return
+	}
+	s.send(context, &Vote{PostID: listing.Posts[0].ID, UserID: username, IsUpvote: true})
+}
+
+// simulateGetHomeFeed reads the personalized feed and now and then hides the
+// top post.
+func (s *Simulator) simulateGetHomeFeed(context actor.Context) {
+	username := s.randomUser()
+	result, err := s.request(context, &GetHomeFeed{Username: username, RecommendedShare: 0.25, Limit: 10})
+	if err != nil {
+		return
+	}
+	listing, ok := result.(*PostListing)
+	if !ok || len(listing.Posts) == 0 {
+		return
+	}
+	if rand.Intn(4) == 0 {
+		s.send(context, &HidePost{PostID: listing.Posts[0].ID, Username: username})
+	}
+}
+
+// send delivers message to the engine inside a span whose context travels in
+// the message header, so the engine's handling joins the same trace.
+func (s *Simulator) send(context actor.Context, message interface{}) {
+	env, span := tracedEnvelope(s.actionSpan, message)
+	defer span.End()
+	context.Send(s.enginePID, env)
+}
+
+// request is send for messages whose response the simulator waits for.
+func (s *Simulator) request(context actor.Context, message interface{}) (interface{}, error) {
+	env, span := tracedEnvelope(s.actionSpan, message)
+	defer span.End()
+	future := actor.NewFuture(context.ActorSystem(), time.Second)
+	env.Sender = future.PID()
+	context.Send(s.enginePID, env)
+	return future.Result()
 }
 
 func (s *Simulator) randomUser() string {
@@ -260,10 +781,10 @@ func (s *Simulator) randomPost() string {
 
 func (s *Simulator) printSimulationStats(context actor.Context) {
 	fmt.Println("\nSimulation completed. Requesting final statistics...")
-	context.Send(s.enginePID, &PrintSubredditPostsAndComments{})
-	context.Send(s.enginePID, &GetSimulationStats{})
+	s.send(context, &PrintSubredditPostsAndComments{})
+	s.send(context, &GetSimulationStats{})
 }
 
 func (s *Simulator) printUserActions(context actor.Context) {
-	context.Send(s.enginePID, &PrintUserActions{})
+	s.send(context, &PrintUserActions{JSONLines: s.jsonActions})
 }
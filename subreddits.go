@@ -0,0 +1,98 @@
+package main
+
+import "fmt"
+
+// citeRule prefixes reason with the subreddit rule it refers to. A zero rule
+// number leaves reason unchanged.
+func citeRule(subreddit *Subreddit, ruleNumber int, reason string) (string, error) {
+	if ruleNumber == 0 {
+		return reason, nil
+	}
+	if ruleNumber < 0 || ruleNumber > len(subreddit.Rules) {
+		return "", fmt.Errorf("%s has no rule %d", subreddit.Name, ruleNumber)
+	}
+	cited := fmt.Sprintf("Rule %d: %s", ruleNumber, subreddit.Rules[ruleNumber-1].Title)
+	if reason != "" {
+		cited += " - " + reason
+	}
+	return cited, nil
+}
+
+func (e *Engine) getSubreddit(subredditName, username string) (*SubredditInfo, error) {
+	subreddit, exists := e.subreddits[subredditName]
+	if !exists {
+		return nil, fmt.Errorf("subreddit %s does not exist", subredditName)
+	}
+	if err := e.checkCanView(subreddit, username); err != nil {
+		return nil, err
+	}
+	return &SubredditInfo{
+		Name:        subreddit.Name,
+		Creator:     subreddit.Creator,
+		Created:     subreddit.Created,
+		Type:        subreddit.Type,
+		Description: subreddit.Description,
+		Sidebar:     subreddit.Sidebar,
+		Rules:       append([]SubredditRule(nil), subreddit.Rules...),
+		Moderators:  append([]string(nil), subreddit.Moderators...),
+		MemberCount: len(subreddit.Members),
+	}, nil
+}
+
+func (e *Engine) updateSubredditInfo(subredditName, moderator, description, sidebar string) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	subreddit.Description = description
+	subreddit.Sidebar = sidebar
+	e.logModAction(subreddit, UserAction{Kind: ActionEditSubreddit, Actor: moderator, Subreddit: subredditName, Content: description})
+	return nil
+}
+
+func (e *Engine) addSubredditRule(subredditName, moderator string, rule SubredditRule) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if rule.Title == "" {
+		return fmt.Errorf("subreddit rule needs a title")
+	}
+	subreddit.Rules = append(subreddit.Rules, rule)
+	e.logModAction(subreddit, UserAction{Kind: ActionAddRule, Actor: moderator, Subreddit: subredditName,
+		Content: fmt.Sprintf("Rule %d: %s", len(subreddit.Rules), rule.Title)})
+	return nil
+}
+
+func (e *Engine) editSubredditRule(subredditName, moderator string, ruleNumber int, rule SubredditRule) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if ruleNumber < 1 || ruleNumber > len(subreddit.Rules) {
+		return fmt.Errorf("%s has no rule %d", subredditName, ruleNumber)
+	}
+	if rule.Title == "" {
+		return fmt.Errorf("subreddit rule needs a title")
+	}
+	subreddit.Rules[ruleNumber-1] = rule
+	e.logModAction(subreddit, UserAction{Kind: ActionEditRule, Actor: moderator, Subreddit: subredditName,
+		Content: fmt.Sprintf("Rule %d: %s", ruleNumber, rule.Title)})
+	return nil
+}
+
+func (e *Engine) removeSubredditRule(subredditName, moderator string, ruleNumber int) error {
+	subreddit, err := e.moderatedSubreddit(subredditName, moderator)
+	if err != nil {
+		return err
+	}
+	if ruleNumber < 1 || ruleNumber > len(subreddit.Rules) {
+		return fmt.Errorf("%s has no rule %d", subredditName, ruleNumber)
+	}
+	title := subreddit.Rules[ruleNumber-1].Title
+	subreddit.Rules = append(subreddit.Rules[:ruleNumber-1], subreddit.Rules[ruleNumber:]...)
+	e.renumberReportedRules(subredditName, ruleNumber)
+	e.logModAction(subreddit, UserAction{Kind: ActionRemoveRule, Actor: moderator, Subreddit: subredditName,
+		Content: fmt.Sprintf("Rule %d: %s", ruleNumber, title)})
+	return nil
+}
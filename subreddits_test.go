@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestPrivateSubredditVisibleToApprovedUsers(t *testing.T) {
+	e := newTestEngine(t)
+	e.registerUser("carol")
+	if err := e.setSubredditType("r/test", "alice", SubredditPrivate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.getSubreddit("r/test", "carol"); err == nil {
+		t.Fatal("an unapproved user can see a private subreddit")
+	}
+	if _, err := e.getSubredditListing(&GetSubredditListing{SubredditName: "r/test", Username: "carol"}); err == nil {
+		t.Fatal("an unapproved user can list a private subreddit")
+	}
+	if _, err := e.getSubreddit("r/test", "alice"); err != nil {
+		t.Fatalf("moderator cannot see their private subreddit: %v", err)
+	}
+	if err := e.approveUser("r/test", "alice", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.getSubreddit("r/test", "carol"); err != nil {
+		t.Fatalf("approved user cannot see the private subreddit: %v", err)
+	}
+}
+
+func TestRemovingRuleRenumbersReports(t *testing.T) {
+	e := newTestEngine(t)
+	e.registerUser("carol")
+	for _, title := range []string{"No spam", "Stay on topic"} {
+		if err := e.addSubredditRule("r/test", "alice", SubredditRule{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.report(&Report{Reporter: "bob", TargetType: ReportPost, PostID: "Post 1", RuleNumber: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.report(&Report{Reporter: "carol", TargetType: ReportPost, PostID: "Post 1", RuleNumber: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.removeSubredditRule("r/test", "alice", 1); err != nil {
+		t.Fatal(err)
+	}
+	queue, err := e.getModQueue(&GetModQueue{SubredditName: "r/test", Moderator: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Items) != 1 || len(queue.Items[0].Reports) != 2 {
+		t.Fatalf("mod queue = %+v, want one item with two reports", queue.Items)
+	}
+	want := []ReportEntry{{Reporter: "bob", RuleNumber: 1, RuleTitle: "Stay on topic"}, {Reporter: "carol", RuleNumber: 0, RuleTitle: "No spam"}}
+	for i, entry := range queue.Items[0].Reports {
+		if entry.Reporter != want[i].Reporter || entry.RuleNumber != want[i].RuleNumber || entry.RuleTitle != want[i].RuleTitle {
+			t.Errorf("report %d = rule %d %q by %s, want rule %d %q by %s", i, entry.RuleNumber, entry.RuleTitle, entry.Reporter,
+				want[i].RuleNumber, want[i].RuleTitle, want[i].Reporter)
+		}
+	}
+}
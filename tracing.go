@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/asynkron/protoactor-go/actor"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("reddit-clone")
+
+var propagator = propagation.TraceContext{}
+
+// initTracing installs a tracer provider exporting to stdout ("stdout") or to
+// the given file path. An empty target leaves tracing disabled.
+func initTracing(target string) (func(), error) {
+	if target == "" {
+		return func() {}, nil
+	}
+
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if target != "stdout" {
+		f, err := os.Create(target)
+		if err != nil {
+			return nil, err
+		}
+		file = f
+		out = f
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(out))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+
+	return func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			fmt.Printf("Tracing shutdown failed: %v\n", err)
+		}
+		if file != nil {
+			file.Close()
+		}
+	}, nil
+}
+
+// envelopeCarrier writes trace context into the header of an outgoing message.
+type envelopeCarrier struct {
+	env *actor.MessageEnvelope
+}
+
+func (c envelopeCarrier) Get(key string) string { return c.env.GetHeader(key) }
+func (c envelopeCarrier) Set(key, value string) { c.env.SetHeader(key, value) }
+func (c envelopeCarrier) Keys() []string {
+	if c.env.Header == nil {
+		return nil
+	}
+	return c.env.Header.Keys()
+}
+
+// headerCarrier reads trace context from the header of an incoming message.
+type headerCarrier struct {
+	header actor.ReadonlyMessageHeader
+}
+
+func (c headerCarrier) Get(key string) string { return c.header.Get(key) }
+func (c headerCarrier) Set(key, value string) {}
+func (c headerCarrier) Keys() []string        { return c.header.Keys() }
+
+// startActionSpan starts the root span of a simulated action, so that every
+// message sent while performing it belongs to one trace.
+func startActionSpan(number int) trace.Span {
+	_, span := tracer.Start(context.Background(), "simulate action",
+		trace.WithAttributes(attribute.Int("simulator.action", number)))
+	return span
+}
+
+// tracedEnvelope starts a span for sending message, as a child of parent when
+// there is one, and wraps the message in an envelope carrying the span
+// context. The caller ends the returned span.
+func tracedEnvelope(parent trace.Span, message interface{}) (*actor.MessageEnvelope, trace.Span) {
+	ctx := context.Background()
+	if parent != nil {
+		ctx = trace.ContextWithSpan(ctx, parent)
+	}
+	ctx, span := tracer.Start(ctx, "send "+messageName(message),
+		trace.WithSpanKind(trace.SpanKindProducer))
+	env := &actor.MessageEnvelope{Message: message}
+	propagator.Inject(ctx, envelopeCarrier{env: env})
+	return env, span
+}
+
+// startReceiveSpan starts a span for the message being handled, continuing the
+// trace propagated in its header. Untraced messages get a no-op span.
+func startReceiveSpan(actorContext actor.Context) trace.Span {
+	header := actorContext.MessageHeader()
+	if header == nil || header.Get("traceparent") == "" {
+		return trace.SpanFromContext(context.Background())
+	}
+	parent := propagator.Extract(context.Background(), headerCarrier{header: header})
+	_, span := tracer.Start(parent, "engine "+messageName(actorContext.Message()),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("actor.pid", actorContext.Self().String())))
+	return span
+}
+
+func messageName(message interface{}) string {
+	name := fmt.Sprintf("%T", message)
+	return name[strings.LastIndex(name, ".")+1:]
+}